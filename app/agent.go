@@ -0,0 +1,152 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/digineo/zackup/config"
+	"github.com/sirupsen/logrus"
+)
+
+// AgentOptions configure RunAgent.
+type AgentOptions struct {
+	Server       string // [user@]host of the zackup server
+	Port         uint16 // SSH port, defaults to 22
+	IdentityFile string // SSH private key, optional
+}
+
+// sshArgs returns the SSH options to connect to the server.
+func (o *AgentOptions) sshArgs() []string {
+	args := []string{"-oBatchMode=yes"}
+	if o.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(int(o.Port)))
+	}
+	if o.IdentityFile != "" {
+		args = append(args, "-i", o.IdentityFile)
+	}
+	return args
+}
+
+// RunAgent backs up the local host by pushing it to the zackup server.
+// It fetches a PushPlan, executes the pre-scripts, rsyncs "/" into the
+// host's dataset, executes the post- and finally-scripts, and reports
+// the outcome to the server, which then creates the snapshot.
+func RunAgent(opts AgentOptions) error {
+	l := log.WithFields(logrus.Fields{
+		"prefix": "agent",
+		"server": opts.Server,
+	})
+
+	var out bytes.Buffer
+	l.Info("requesting backup plan")
+	if err := opts.call(l, pushBegin, nil, &out); err != nil {
+		return err
+	}
+	var plan PushPlan
+	if err := json.Unmarshal(out.Bytes(), &plan); err != nil {
+		return fmt.Errorf("invalid plan: %w", err)
+	}
+	l = l.WithField("job", plan.Host)
+	if plan.Skipped {
+		l.Info("backup skipped by server")
+		return nil
+	}
+
+	// redact the secrets from the script output
+	values := make([]string, 0, len(plan.Env))
+	for _, kv := range plan.Env {
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			values = append(values, kv[i+1:])
+		}
+	}
+	defer secrets.add(values)()
+
+	res := runResult{full: plan.Full, scripts: plan.Scripts}
+	err := opts.transfer(l, &plan, &res)
+
+	report := PushReport{
+		Started: plan.Started,
+		Attempt: plan.Attempt,
+		Full:    plan.Full,
+		Scripts: res.scripts,
+		Skipped: errors.Is(err, errSkipped),
+	}
+	if err != nil && !report.Skipped {
+		report.Error = err.Error()
+	}
+	data, jsonErr := json.Marshal(&report)
+	if jsonErr != nil {
+		return fmt.Errorf("encoding report failed: %w", jsonErr)
+	}
+
+	l.Info("reporting result")
+	if callErr := opts.call(l, pushFinish, []string{string(data)}, io.Discard); err == nil {
+		err = callErr
+	}
+	if report.Skipped {
+		l.Info("backup skipped")
+		return nil
+	}
+	return err
+}
+
+// transfer runs the pre-scripts, rsync, the post-scripts and the
+// finally-scripts of plan.
+func (o *AgentOptions) transfer(l *logrus.Entry, plan *PushPlan, res *runResult) error {
+	local := func(env []string) func(context.Context, config.ScriptUnit) error {
+		return func(ctx context.Context, u config.ScriptUnit) error {
+			return executeLocal(ctx, plan.Host, u.Name, unitScript(u, nil), env)
+		}
+	}
+
+	var err error
+	if len(plan.Pre) > 0 {
+		l.Info("executing pre-scripts")
+		err = runScripts(l, res, plan.Pre, plan.Timeout, local(plan.Env))
+		if skipRequested(err) {
+			err = errSkipped
+		}
+	}
+
+	if err == nil {
+		args := append([]string{}, plan.Rsync...)
+		args = append(args,
+			"-e", strings.Join(append([]string{SSHPath}, o.sshArgs()...), " "),
+			"/", o.Server+":/")
+
+		l.Info("starting rsync")
+		err = runRsyncCmd(l, args, nil)
+	}
+
+	if err == nil && len(plan.Post) > 0 {
+		l.Info("executing post-scripts")
+		err = runScripts(l, res, plan.Post, plan.Timeout, local(plan.Env))
+	}
+
+	if len(plan.Finally) > 0 {
+		l.Info("executing finally-scripts")
+		env := append(append([]string{}, plan.Env...), resultEnv(err)...)
+		if finErr := runScripts(l, res, plan.Finally, plan.Timeout, local(env)); err == nil {
+			err = finErr
+		}
+	}
+	return err
+}
+
+// call executes a push protocol command on the server, with input as
+// stdin, and writes its stdout into out.
+func (o *AgentOptions) call(l *logrus.Entry, command string, input []string, out io.Writer) error {
+	args := append(o.sshArgs(), o.Server, command)
+	cmd := exec.Command(SSHPath, args...)
+	if err := pipeScript(context.Background(), l, "agent", cmd, input, out); err != nil {
+		return fmt.Errorf("%s: %w", command, err)
+	}
+	return nil
+}
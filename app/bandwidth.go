@@ -0,0 +1,102 @@
+package app
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/digineo/zackup/config"
+)
+
+// parallelity is the effective number of jobs running in parallel, i.e.
+// the size of the queue (see Queue.Resize). It reflects both the service
+// config and "zackup run --parallel".
+var parallelity int32 = 1
+
+// bandwidthGroups tracks the shares of the group limits, which are in
+// use by running rsync processes.
+var bandwidthGroups = &bandwidthPool{
+	used:    make(map[string]config.Rate),
+	running: make(map[string]int),
+}
+
+type bandwidthPool struct {
+	used    map[string]config.Rate // sum of allocated limits per group
+	running map[string]int         // number of allocations per group
+	sync.Mutex
+}
+
+// bandwidthLimit determines the rsync bandwidth limit for job at time t.
+// The returned release function must be called once rsync has finished.
+//
+// If the job belongs to a bandwidth group, it gets an equal share of the
+// group's remaining limit (not allocated by running jobs of that group),
+// divided among the slots still available. There are as many slots as
+// group members may run concurrently (i.e. the smaller of the effective
+// parallelity and the group size). Since rsync cannot adjust its limit
+// while running, this guarantees the aggregate limit is never exceeded,
+// while bandwidth released by finished jobs is given to the next ones.
+// The smaller of the host and group limit wins.
+//
+// Push hosts are outside of the pool: their rsync runs on the agent,
+// after "zackup receive" has exited, so its share could never be
+// released. They neither count as group members, nor get a share.
+func bandwidthLimit(tree config.Tree, job *config.JobConfig, t time.Time) (config.Rate, func()) {
+	limit := job.Bandwidth.LimitAt(t)
+	if job.Bandwidth == nil || job.Bandwidth.Group == "" || job.IsPush() {
+		return limit, func() {}
+	}
+
+	group := job.Bandwidth.Group
+	groupLimit := tree.Service().BandwidthGroups[group]
+	if groupLimit == 0 {
+		return limit, func() {}
+	}
+
+	members := 0
+	for _, host := range tree.Hosts() {
+		if j := tree.Host(host); j != nil && !j.IsPush() && j.Bandwidth != nil && j.Bandwidth.Group == group {
+			members++
+		}
+	}
+	if p := int(atomic.LoadInt32(&parallelity)); p < members {
+		members = p
+	}
+
+	return bandwidthGroups.acquire(group, groupLimit, members, limit)
+}
+
+// acquire allocates a share of limit for a job of group, which has the
+// given number of slots. hostLimit caps the share, unless it is zero.
+func (p *bandwidthPool) acquire(group string, limit config.Rate, slots int, hostLimit config.Rate) (config.Rate, func()) {
+	p.Lock()
+	defer p.Unlock()
+
+	free := slots - p.running[group]
+	if free < 1 {
+		free = 1
+	}
+	var share config.Rate
+	if used := p.used[group]; used < limit {
+		share = (limit - used) / config.Rate(free)
+	}
+	if share == 0 {
+		share = 1
+	}
+	if hostLimit > 0 && hostLimit < share {
+		share = hostLimit
+	}
+
+	p.used[group] += share
+	p.running[group]++
+
+	var once sync.Once
+	return share, func() {
+		once.Do(func() {
+			p.Lock()
+			defer p.Unlock()
+			p.used[group] -= share
+			p.running[group]--
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/digineo/zackup/config"
+)
+
+func TestBandwidthPool(t *testing.T) {
+	t.Parallel()
+
+	p := &bandwidthPool{
+		used:    make(map[string]config.Rate),
+		running: make(map[string]int),
+	}
+	check := func(expected, actual config.Rate) {
+		t.Helper()
+		if actual != expected {
+			t.Errorf("expected share %d, got %d", expected, actual)
+		}
+	}
+
+	// host limit below the share leaves more for the others
+	a, releaseA := p.acquire("office", 900, 3, 100)
+	check(100, a)
+	b, releaseB := p.acquire("office", 900, 3, 0)
+	check(400, b)
+	c, releaseC := p.acquire("office", 900, 3, 0)
+	check(400, c)
+
+	// more jobs than slots (e.g. after a resize) get what is left
+	d, releaseD := p.acquire("office", 900, 3, 0)
+	check(1, d)
+	releaseD()
+
+	// released shares are given to the next job
+	releaseA()
+	releaseA() // no-op
+	e, releaseE := p.acquire("office", 900, 3, 0)
+	check(100, e)
+	releaseB()
+	releaseC()
+	releaseE()
+
+	if p.used["office"] != 0 || p.running["office"] != 0 {
+		t.Errorf("expected empty pool, got %d in %d jobs", p.used["office"], p.running["office"])
+	}
+	f, releaseF := p.acquire("office", 900, 3, 0)
+	check(300, f)
+	releaseF()
+}
+
+func TestBandwidthLimitPush(t *testing.T) {
+	t.Parallel()
+
+	tree := loadTree(t, map[string]string{
+		"config.yml":            "bandwidth_groups: {branch: 900}\n",
+		"globals.yml":           "bandwidth: {group: branch}\n",
+		"hosts/laptop.com.yml":  "transport: {type: push}\nbandwidth: {limit: 100}\n",
+		"hosts/workstation.yml": "bandwidth: {limit: 2000}\n",
+	})
+
+	limit, release := bandwidthLimit(tree, tree.Host("laptop.com"), time.Now())
+	defer release()
+	if limit != 100 {
+		t.Errorf("expected the push host's own limit, got %d", limit)
+	}
+
+	bandwidthGroups.Lock()
+	running := bandwidthGroups.running["branch"]
+	bandwidthGroups.Unlock()
+	if running != 0 {
+		t.Errorf("expected push host outside of the pool, got %d allocations", running)
+	}
+}
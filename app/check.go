@@ -0,0 +1,371 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digineo/zackup/config"
+	"github.com/sirupsen/logrus"
+)
+
+// checkTimeout limits each remote command of CheckHost.
+const checkTimeout = time.Minute
+
+// HostReport is the result of CheckHost.
+type HostReport struct {
+	Host      string        `json:"host"`
+	Transport string        `json:"transport"`
+	Status    CheckStatus   `json:"status"` // worst status of all checks
+	Checks    []CheckResult `json:"checks"`
+}
+
+func (r *HostReport) add(results ...CheckResult) {
+	for _, res := range results {
+		r.Checks = append(r.Checks, res)
+		if res.Status > r.Status {
+			r.Status = res.Status
+		}
+	}
+}
+
+// CheckHost verifies the prerequisites of a backup of job, without
+// taking one: it connects to the host (verifying the SSH host key),
+// checks the version of the remote rsync, whether the include paths (or
+// the datasets for zfs send) exist and are readable, and runs "sh -n" on
+// the remote hook scripts. Hosts, which are woken up or backed up
+// opportunistically, are only checked if they are online.
+func CheckHost(job *config.JobConfig) *HostReport {
+	r := &HostReport{Host: job.Host(), Transport: job.TransportType()}
+	l := log.WithFields(logrus.Fields{
+		"prefix": "check",
+		"job":    r.Host,
+	})
+
+	if job.IsPush() {
+		r.add(CheckResult{
+			Name:    "transport",
+			Status:  CheckWarn,
+			Message: "host pushes its backups with zackup agent, not checked",
+		})
+		return r
+	}
+
+	if job.IsOpportunistic() || job.WakeOnLAN != nil || job.TransportType() == config.TransportRsyncd {
+		res := CheckResult{Name: "reachable", Message: job.ProbeAddress()}
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		err := tcpProbe(ctx, job.ProbeAddress())
+		cancel()
+		if err != nil {
+			res.Status = CheckFail
+			res.Message = err.Error()
+			res.Hint = "check that the host is up, and reachable from the backup server"
+			if job.TransportType() != config.TransportRsyncd {
+				// offline is expected for these hosts
+				res.Status = CheckWarn
+				res.Hint = "host is offline, check again when it is up"
+			}
+			r.add(res)
+			return r
+		}
+		r.add(res)
+	}
+
+	m := newTransport(job)
+	if ssh, ok := m.(*sshMaster); ok {
+		res := ssh.check()
+		r.add(res)
+		if res.Status == CheckFail {
+			return r
+		}
+	}
+
+	l.WithField("transport", r.Transport).Info("connecting")
+	if err := m.connect(); err != nil {
+		r.add(CheckResult{
+			Name:    "connect",
+			Status:  CheckFail,
+			Message: err.Error(),
+			Hint:    "check the transport config of the host",
+		})
+		return r
+	}
+	defer m.close()
+
+	if job.TransportType() == config.TransportZFSSend {
+		r.add(checkPaths(m, "dataset", datasetsScript(job.Transport.Datasets), "")...)
+	} else {
+		r.add(checkRemoteRsync(m))
+
+		base := ""
+		if job.TransportType() == config.TransportLocal {
+			base = job.Transport.Path
+		}
+		prefixes := []string{"/"}
+		if job.RSync != nil && len(job.RSync.Included) > 0 {
+			prefixes = includePrefixes(job.RSync.Included)
+		}
+		r.add(checkPaths(m, "include", includesScript(base, prefixes), base)...)
+	}
+
+	r.add(checkRemoteScripts(job, m)...)
+	return r
+}
+
+// check connects to the host without multiplexing, to verify the host
+// key and the authentication.
+func (c *sshMaster) check() CheckResult {
+	args := []string{
+		"-S", "none",
+		"-o", "BatchMode=yes",
+		"-o", "StrictHostKeyChecking=yes",
+	}
+	if c.connectTimeout > 0 {
+		args = append(args, "-o", fmt.Sprintf("ConnectTimeout=%d", c.connectTimeout))
+	}
+	args = append(args,
+		"-p", strconv.Itoa(int(c.port)),
+		"-x",
+		"-l", c.user,
+		c.host,
+		"true",
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, SSHPath, args...).CombinedOutput()
+
+	res := CheckResult{Name: "ssh", Message: fmt.Sprintf("%s@%s:%d", c.user, c.host, c.port)}
+	if err == nil {
+		return res
+	}
+
+	output := strings.TrimSpace(string(out))
+	res.Status = CheckFail
+	res.Message = err.Error()
+	if i := strings.LastIndexByte(output, '\n'); i >= 0 {
+		res.Message = output[i+1:]
+	} else if output != "" {
+		res.Message = output
+	}
+
+	knownHost := c.host
+	if c.port != 22 {
+		knownHost = fmt.Sprintf("[%s]:%d", c.host, c.port)
+	}
+	switch {
+	case strings.Contains(output, "REMOTE HOST IDENTIFICATION HAS CHANGED"):
+		res.Name = "ssh host key"
+		res.Hint = fmt.Sprintf("verify the new host key, then remove the old one with \"ssh-keygen -R '%s'\"", knownHost)
+	case strings.Contains(output, "Host key verification failed"):
+		res.Name = "ssh host key"
+		res.Hint = fmt.Sprintf("verify and add the host key, e.g. with \"ssh-keyscan -p %d %s >> ~/.ssh/known_hosts\"", c.port, c.host)
+	case strings.Contains(output, "Permission denied"):
+		res.Name = "ssh authentication"
+		res.Hint = fmt.Sprintf("add the public key of the backup server to ~%s/.ssh/authorized_keys on the host", c.user)
+	default:
+		res.Hint = fmt.Sprintf("check that the host is up, and SSH is reachable on port %d", c.port)
+	}
+	return res
+}
+
+// remoteOutput executes script on the host, and returns its output.
+func remoteOutput(m transport, name string, script []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	err := m.stream(ctx, name, script, &out)
+	return out.String(), err
+}
+
+// unsupported returns a warning for checks, which need a shell on hosts
+// using a transport without one.
+func unsupported(name string, err error) (CheckResult, bool) {
+	if !errors.Is(err, errNoShell) {
+		return CheckResult{}, false
+	}
+	return CheckResult{
+		Name:    name,
+		Status:  CheckWarn,
+		Message: "not supported by transport",
+	}, true
+}
+
+func checkRemoteRsync(m transport) CheckResult {
+	out, err := remoteOutput(m, "check.rsync", []string{
+		"if command -v rsync >/dev/null 2>&1; then rsync --version; else echo 'rsync not found'; fi",
+	})
+	if res, ok := unsupported("remote rsync", err); ok {
+		return res
+	}
+
+	res := CheckResult{Name: "remote rsync"}
+	if err != nil {
+		res.Status = CheckFail
+		res.Message = err.Error()
+		res.Hint = "check the remote shell of the host"
+		return res
+	}
+
+	v, ok := parseRsyncVersion(out)
+	switch {
+	case strings.HasPrefix(out, "rsync not found"):
+		res.Status = CheckFail
+		res.Message = "rsync not found"
+		res.Hint = "install rsync on the host"
+	case !ok:
+		res.Status = CheckWarn
+		res.Message = "unknown rsync version"
+		res.Hint = fmt.Sprintf("make sure rsync on the host is (compatible to) rsync %d.%d.%d or newer",
+			minRsyncVersion[0], minRsyncVersion[1], minRsyncVersion[2])
+	case rsyncTooOld(v):
+		res.Status = CheckFail
+		res.Message = fmt.Sprintf("%d.%d.%d is too old, %d.%d.%d or newer is required", v[0], v[1], v[2],
+			minRsyncVersion[0], minRsyncVersion[1], minRsyncVersion[2])
+		res.Hint = "upgrade rsync on the host"
+	default:
+		res.Message = fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+	}
+	return res
+}
+
+// includesScript returns a script, which prints "ok PATH", "missing
+// PATH" or "unreadable PATH" for each of the given paths below base.
+func includesScript(base string, paths []string) []string {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = config.ShellQuote(filepath.Join("/", base, p))
+	}
+	return []string{
+		"for p in " + strings.Join(quoted, " ") + "; do",
+		`  if [ ! -e "$p" ]; then echo "missing $p"`,
+		`  elif [ ! -r "$p" ] || { [ -d "$p" ] && [ ! -x "$p" ]; }; then echo "unreadable $p"`,
+		`  else echo "ok $p"; fi`,
+		"done",
+	}
+}
+
+// datasetsScript returns a script, which prints "ok DATASET" or "missing
+// DATASET" for each of the given datasets.
+func datasetsScript(datasets []string) []string {
+	quoted := make([]string, len(datasets))
+	for i, ds := range datasets {
+		quoted[i] = config.ShellQuote(ds)
+	}
+	return []string{
+		"for ds in " + strings.Join(quoted, " ") + "; do",
+		`  if zfs list -H -o name "$ds" >/dev/null 2>&1; then echo "ok $ds"; else echo "missing $ds"; fi`,
+		"done",
+	}
+}
+
+// checkPaths executes a script created by includesScript or
+// datasetsScript, and converts its output into results. The base
+// directory is removed from the reported paths.
+func checkPaths(m transport, kind string, script []string, base string) []CheckResult {
+	out, err := remoteOutput(m, "check."+kind, script)
+	if res, ok := unsupported(kind, err); ok {
+		return []CheckResult{res}
+	}
+	if err != nil {
+		return []CheckResult{{
+			Name:    kind,
+			Status:  CheckFail,
+			Message: err.Error(),
+			Hint:    "check the remote shell of the host",
+		}}
+	}
+	return parsePathChecks(kind, out, base)
+}
+
+// parsePathChecks parses the output of includesScript or datasetsScript.
+func parsePathChecks(kind, out, base string) (results []CheckResult) {
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		f := strings.SplitN(scanner.Text(), " ", 2)
+		if len(f) != 2 {
+			continue
+		}
+		name := f[1]
+		if base != "" {
+			if rel, err := filepath.Rel(filepath.Join("/", base), name); err == nil {
+				name = filepath.Join("/", rel)
+			}
+		}
+
+		res := CheckResult{Name: kind + " " + name, Message: f[0]}
+		switch f[0] {
+		case "ok":
+		case "missing":
+			res.Status = CheckFail
+			res.Message = "does not exist"
+			res.Hint = fmt.Sprintf("create %s on the host, or remove it from the config", name)
+		case "unreadable":
+			res.Status = CheckFail
+			res.Message = "not readable"
+			res.Hint = fmt.Sprintf("grant the SSH user read access to %s", name)
+		default:
+			continue
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// checkRemoteScripts renders the remote hook scripts, and runs "sh -n"
+// on each of them on the host, whose shell may differ from the one on
+// the backup server. Units with a custom interpreter are skipped.
+func checkRemoteScripts(job *config.JobConfig, m transport) []CheckResult {
+	r := newBackupRun(job, false)
+	if err := r.prepare(time.Now(), 0); err != nil {
+		return []CheckResult{{
+			Name:    "scripts",
+			Status:  CheckFail,
+			Message: err.Error(),
+			Hint:    "check the hook scripts and secrets with \"zackup config check\"",
+		}}
+	}
+	defer r.release()
+
+	var results []CheckResult
+	for _, units := range [][]config.ScriptUnit{r.hooks.pre, r.hooks.post, r.hooks.finally} {
+		for _, u := range units {
+			if len(u.Interpreter) > 0 || len(u.Lines) == 0 {
+				continue
+			}
+
+			script := make([]string, 0, len(u.Lines)+2)
+			script = append(script, "sh -n 2>&1 <<'"+heredocEOF+"' || true")
+			script = append(script, u.Lines...)
+			script = append(script, heredocEOF)
+
+			name := "script " + u.Name
+			out, err := remoteOutput(m, "check."+u.Name, script)
+			if res, ok := unsupported(name, err); ok {
+				return []CheckResult{res}
+			}
+
+			res := CheckResult{Name: name, Message: "sh -n"}
+			switch out = strings.TrimSpace(out); {
+			case err != nil:
+				res.Status = CheckFail
+				res.Message = err.Error()
+				res.Hint = "check the remote shell of the host"
+			case out != "":
+				res.Status = CheckFail
+				res.Message = Redact(out)
+				res.Hint = "fix the syntax error in " + u.Name
+			}
+			results = append(results, res)
+		}
+	}
+	return results
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePathChecks(t *testing.T) {
+	t.Parallel()
+
+	out := "ok /srv/nfs/etc\nmissing /srv/nfs/missing\nunreadable /srv/nfs/home\n+ for p in\nok /srv/nfs\n"
+	results := parsePathChecks("include", out, "/srv/nfs")
+
+	expected := []struct {
+		name   string
+		status CheckStatus
+	}{
+		{"include /etc", CheckPass},
+		{"include /missing", CheckFail},
+		{"include /home", CheckFail},
+		{"include /", CheckPass},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %+v", len(expected), results)
+	}
+	for i, e := range expected {
+		if r := results[i]; r.Name != e.name || r.Status != e.status {
+			t.Errorf("%d: expected %s (%s), got %s (%s)", i, e.name, e.status, r.Name, r.Status)
+		}
+	}
+}
+
+func TestCheckHostLocal(t *testing.T) {
+	t.Parallel()
+
+	src := t.TempDir()
+	if err := os.Mkdir(filepath.Join(src, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tree := loadTree(t, map[string]string{
+		"config.yml":  "---\n",
+		"globals.yml": "---\n",
+		"hosts/nas.example.com.yml": "transport: {type: local, path: " + src + "}\n" +
+			"rsync: {include: [/etc, /missing]}\n" +
+			"pre_script: echo ok\n" +
+			"post_script: 'if true; then'\n",
+	})
+
+	r := CheckHost(tree.Host("nas.example.com"))
+	if r.Status != CheckFail {
+		t.Errorf("expected report to fail, got %s", r.Status)
+	}
+
+	status := make(map[string]CheckStatus)
+	for _, c := range r.Checks {
+		status[c.Name] = c.Status
+	}
+	for name, expected := range map[string]CheckStatus{
+		"include /etc":     CheckPass,
+		"include /missing": CheckFail,
+	} {
+		if s, ok := status[name]; !ok || s != expected {
+			t.Errorf("expected %s to be %s, got %+v", name, expected, r.Checks)
+		}
+	}
+
+	var pre, post *CheckResult
+	for i, c := range r.Checks {
+		switch {
+		case strings.HasSuffix(c.Name, "#pre_script"):
+			pre = &r.Checks[i]
+		case strings.HasSuffix(c.Name, "#post_script"):
+			post = &r.Checks[i]
+		}
+	}
+	if pre == nil || pre.Status != CheckPass {
+		t.Errorf("expected pre_script to pass, got %+v", pre)
+	}
+	if post == nil || post.Status != CheckFail {
+		t.Errorf("expected post_script to fail, got %+v", post)
+	}
+}
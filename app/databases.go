@@ -0,0 +1,107 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/digineo/zackup/config"
+	"github.com/sirupsen/logrus"
+)
+
+// runDatabases dumps the given databases into streamsDir(host), each
+// into its own file, preceded by a dump of the globals. Dumps of
+// databases which no longer exist are removed afterwards.
+func runDatabases(l *logrus.Entry, m transport, host string, dbs []config.DatabaseConfig, hooks *hookScripts, res *runResult) error {
+	for i := range dbs {
+		d := &dbs[i]
+		dl := l.WithFields(logrus.Fields{
+			"database": d.DirName(),
+			"dbtype":   d.Type,
+		})
+
+		names := d.Databases
+		if d.DumpsAll() {
+			var err error
+			if names, err = listDatabases(dl, m, d, hooks, res); err != nil {
+				return err
+			}
+		}
+
+		units := []streamUnit{dumpUnit(d, config.GlobalsDump, d.GlobalsScript())}
+		for _, db := range names {
+			units = append(units, dumpUnit(d, db, d.DumpScript(db)))
+		}
+
+		keep := make(map[string]bool, len(units))
+		for _, u := range units {
+			if err := runStream(dl, m, host, u, hooks.env, res); err != nil {
+				if exitCode(err) == config.DumpIncompleteExitCode {
+					return fmt.Errorf("%s: dump incomplete (trailer missing): %w", u.file, err)
+				}
+				return err
+			}
+			keep[filepath.Base(u.file)] = true
+		}
+
+		removeStaleDumps(dl, filepath.Join(streamsDir(host), d.DirName()), keep)
+	}
+	return nil
+}
+
+// listDatabases queries the database names from the server.
+func listDatabases(l *logrus.Entry, m transport, d *config.DatabaseConfig, hooks *hookScripts, res *runResult) ([]string, error) {
+	var out bytes.Buffer
+	unit := config.ScriptUnit{
+		Name:  "databases/" + d.DirName() + "/list",
+		Lines: d.ListScript(),
+	}
+
+	err := runScripts(l, res, []config.ScriptUnit{unit}, hooks.timeout, func(ctx context.Context, u config.ScriptUnit) error {
+		out.Reset()
+		return m.stream(ctx, u.Name, unitScript(u, hooks.env), &out)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dbs, skipped := d.ParseList(out.String())
+	if len(skipped) > 0 {
+		l.WithField("skipped", skipped).Warn("skipping databases with unusable names")
+	}
+	return dbs, nil
+}
+
+func dumpUnit(d *config.DatabaseConfig, db string, script []string) streamUnit {
+	file := d.File(db)
+	return streamUnit{
+		ScriptUnit: config.ScriptUnit{Name: "databases/" + file, Lines: script},
+		file:       file,
+		minSize:    1,
+		timeout:    config.DefaultStreamTimeout,
+	}
+}
+
+// removeStaleDumps removes files from dir, which are not in keep.
+func removeStaleDumps(l *logrus.Entry, dir string, keep map[string]bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		l.WithError(err).Warn("failed to list dumps")
+		return
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || keep[e.Name()] || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		name := filepath.Join(dir, e.Name())
+		if err := os.Remove(name); err != nil {
+			l.WithError(err).WithField("file", name).Warn("failed to remove stale dump")
+			continue
+		}
+		l.WithField("file", e.Name()).Info("removed stale dump")
+	}
+}
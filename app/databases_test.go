@@ -0,0 +1,32 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveStaleDumps(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"_globals.sql", "app.sql", "dropped.sql", ".app.sql.partial"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("--\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removeStaleDumps(log.WithField("job", "example.com"), dir, map[string]bool{
+		"_globals.sql": true,
+		"app.sql":      true,
+	})
+
+	var names []string
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if len(names) != 3 || names[0] != ".app.sql.partial" || names[1] != "_globals.sql" || names[2] != "app.sql" {
+		t.Errorf("unexpected files %v", names)
+	}
+}
@@ -0,0 +1,238 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/digineo/zackup/config"
+)
+
+// CheckStatus is the outcome of a single Doctor check.
+type CheckStatus int
+
+// Possible CheckStatus values.
+const (
+	CheckPass CheckStatus = iota
+	CheckWarn
+	CheckFail
+)
+
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckPass:
+		return "pass"
+	case CheckWarn:
+		return "warn"
+	case CheckFail:
+		return "fail"
+	}
+	return "unknown"
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (s CheckStatus) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(s.String())), nil
+}
+
+// CheckResult describes the outcome of a single Doctor (or CheckHost)
+// check.
+type CheckResult struct {
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message"`
+	Hint    string      `json:"hint,omitempty"` // how to fix the problem, empty if passed
+}
+
+// minRsyncVersion is the oldest rsync supporting all arguments used by
+// zackup (--delete-delay was added in 3.0.0).
+var minRsyncVersion = [3]int{3, 0, 0}
+
+// Doctor checks the prerequisites of the backup server implied by the
+// service config: the required binaries, the root dataset, the mount
+// base and the length of the SSH ControlPath sockets. It sets the app
+// globals (RootDataset, MountBase, RSyncPath, SSHPath) from svc.
+func Doctor(svc *config.ServiceConfig) []CheckResult {
+	applyServiceConfig(svc)
+
+	results := []CheckResult{
+		checkBinary("zfs", "zfs", "install the ZFS utilities"),
+		checkBinary("rsync", RSyncPath, "install rsync, or set rsync_bin in config.yml"),
+		checkBinary("ssh", SSHPath, "install OpenSSH, or set ssh_bin in config.yml"),
+		checkBinary("sh", "sh", "install a POSIX shell"),
+	}
+	if results[1].Status == CheckPass {
+		results = append(results, checkRsyncVersion(RSyncPath))
+	}
+	if results[0].Status == CheckPass {
+		results = append(results, checkRootDataset(RootDataset, MountBase)...)
+	}
+	results = append(results,
+		checkMountBase(MountBase),
+		checkControlPath(filepath.Join(MountBase, controlPathName)),
+	)
+	if os.Geteuid() != 0 {
+		results = append(results, CheckResult{
+			Name:    "privileges",
+			Status:  CheckWarn,
+			Message: "not running as root",
+			Hint:    "run zackup as root, or delegate the required permissions with \"zfs allow\"",
+		})
+	}
+	return results
+}
+
+func checkBinary(name, path, hint string) CheckResult {
+	res := CheckResult{Name: name + " binary"}
+	full, err := exec.LookPath(path)
+	if err != nil {
+		res.Status = CheckFail
+		res.Message = err.Error()
+		res.Hint = hint
+		return res
+	}
+	res.Message = full
+	return res
+}
+
+var rsyncVersionRe = regexp.MustCompile(`version v?(\d+)\.(\d+)\.(\d+)`)
+
+// parseRsyncVersion extracts the version from the output of
+// "rsync --version".
+func parseRsyncVersion(out string) (v [3]int, ok bool) {
+	m := rsyncVersionRe.FindStringSubmatch(out)
+	if m == nil {
+		return v, false
+	}
+	for i := range v {
+		v[i], _ = strconv.Atoi(m[i+1])
+	}
+	return v, true
+}
+
+func checkRsyncVersion(path string) CheckResult {
+	res := CheckResult{Name: "rsync version"}
+	out, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		res.Status = CheckFail
+		res.Message = fmt.Sprintf("%s --version failed: %v", path, err)
+		res.Hint = "check the rsync installation"
+		return res
+	}
+
+	v, ok := parseRsyncVersion(string(out))
+	if !ok {
+		res.Status = CheckWarn
+		res.Message = "unknown rsync version"
+		res.Hint = fmt.Sprintf("make sure %s is (compatible to) rsync %d.%d.%d or newer", path,
+			minRsyncVersion[0], minRsyncVersion[1], minRsyncVersion[2])
+		return res
+	}
+
+	res.Message = fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+	if rsyncTooOld(v) {
+		res.Status = CheckFail
+		res.Message += fmt.Sprintf(" is too old, %d.%d.%d or newer is required (for --delete-delay)",
+			minRsyncVersion[0], minRsyncVersion[1], minRsyncVersion[2])
+		res.Hint = "upgrade rsync, or set rsync_bin in config.yml to a newer binary"
+	}
+	return res
+}
+
+// rsyncTooOld reports whether v is older than minRsyncVersion.
+func rsyncTooOld(v [3]int) bool {
+	for i := range v {
+		if v[i] != minRsyncVersion[i] {
+			return v[i] < minRsyncVersion[i]
+		}
+	}
+	return false
+}
+
+// checkRootDataset checks that the root dataset exists, and is mounted
+// at the mount base, since per-host datasets inherit their mountpoint.
+func checkRootDataset(name, mountBase string) []CheckResult {
+	res := CheckResult{Name: "root_dataset"}
+	o, e, err := execZFS("get", "-H", "-p", "-o", "property,value", "mountpoint,mounted", name)
+	if err != nil {
+		res.Status = CheckFail
+		res.Message = fmt.Sprintf("dataset %s not found: %s", name, strings.TrimSpace(e.String()))
+		res.Hint = fmt.Sprintf("create it with \"zfs create -o compression=lz4 -o mountpoint=%s %s\", "+
+			"or adjust root_dataset in config.yml", mountBase, name)
+		return []CheckResult{res}
+	}
+	res.Message = name
+
+	props := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(o.String()), "\n") {
+		if f := strings.SplitN(line, "\t", 2); len(f) == 2 {
+			props[f[0]] = f[1]
+		}
+	}
+
+	mnt := CheckResult{Name: "root_dataset mountpoint", Message: props["mountpoint"]}
+	switch {
+	case filepath.Clean(props["mountpoint"]) != filepath.Clean(mountBase):
+		mnt.Status = CheckFail
+		mnt.Message = fmt.Sprintf("%s is mounted at %s, but mount_base is %s", name, props["mountpoint"], mountBase)
+		mnt.Hint = fmt.Sprintf("run \"zfs set mountpoint=%s %s\", or adjust mount_base in config.yml", mountBase, name)
+	case props["mounted"] != "yes":
+		mnt.Status = CheckFail
+		mnt.Message = fmt.Sprintf("%s is not mounted", name)
+		mnt.Hint = fmt.Sprintf("run \"zfs mount %s\"", name)
+	}
+	return []CheckResult{res, mnt}
+}
+
+// checkMountBase checks that files can be created in the mount base.
+func checkMountBase(dir string) CheckResult {
+	res := CheckResult{Name: "mount_base", Message: dir}
+	fail := func(err error, hint string) CheckResult {
+		res.Status = CheckFail
+		res.Message = err.Error()
+		res.Hint = hint
+		return res
+	}
+
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return fail(err, "create the root dataset, or adjust mount_base in config.yml")
+	}
+	if !fi.IsDir() {
+		return fail(fmt.Errorf("%s is not a directory", dir), "adjust mount_base in config.yml")
+	}
+
+	f, err := os.CreateTemp(dir, ".zackup-doctor-")
+	if err != nil {
+		return fail(err, fmt.Sprintf("make %s writable for the zackup user", dir))
+	}
+	f.Close()
+	if err = os.Remove(f.Name()); err != nil {
+		return fail(err, fmt.Sprintf("remove %s", f.Name()))
+	}
+	return res
+}
+
+// checkControlPath checks that the expanded SSH ControlPath fits into a
+// Unix socket address. OpenSSH expands %C to 40 characters, and appends
+// a random suffix of 17 characters while creating the socket.
+func checkControlPath(path string) CheckResult {
+	var addr syscall.RawSockaddrUnix
+	limit := len(addr.Path) - 1 // NUL terminated
+
+	n := len(strings.ReplaceAll(path, "%C", strings.Repeat("x", 40))) + 17
+	res := CheckResult{
+		Name:    "ssh ControlPath",
+		Message: fmt.Sprintf("%s expands to %d of at most %d characters", path, n, limit),
+	}
+	if n > limit {
+		res.Status = CheckFail
+		res.Hint = fmt.Sprintf("use a mount_base of at most %d characters", len(filepath.Dir(path))-(n-limit))
+	}
+	return res
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRsyncVersion(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		out      string
+		expected [3]int
+		ok       bool
+	}{
+		{"rsync  version 3.2.7  protocol version 31\nCopyright (C) 1996-2022 by Andrew Tridgell", [3]int{3, 2, 7}, true},
+		{"rsync  version v3.2.3  protocol version 31", [3]int{3, 2, 3}, true},
+		{"rsync  version 2.6.9  protocol version 29", [3]int{2, 6, 9}, true},
+		{"openrsync: protocol version 29", [3]int{}, false},
+	} {
+		v, ok := parseRsyncVersion(tt.out)
+		if ok != tt.ok || v != tt.expected {
+			t.Errorf("%q: expected %v (%v), got %v (%v)", tt.out, tt.expected, tt.ok, v, ok)
+		}
+	}
+}
+
+func TestCheckControlPath(t *testing.T) {
+	t.Parallel()
+
+	if res := checkControlPath(filepath.Join("/zpool/zackup", controlPathName)); res.Status != CheckPass {
+		t.Errorf("expected short path to pass, got %+v", res)
+	}
+
+	long := "/" + strings.Repeat("x", 60)
+	res := checkControlPath(filepath.Join(long, controlPathName))
+	if res.Status != CheckFail || res.Hint == "" {
+		t.Errorf("expected long path to fail, got %+v", res)
+	}
+}
+
+func TestCheckMountBase(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if res := checkMountBase(dir); res.Status != CheckPass {
+		t.Errorf("expected %s to pass, got %+v", dir, res)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected temporary file to be removed, got %v", entries)
+	}
+
+	missing := filepath.Join(dir, "missing")
+	if res := checkMountBase(missing); res.Status != CheckFail || res.Hint == "" {
+		t.Errorf("expected %s to fail, got %+v", missing, res)
+	}
+}
@@ -0,0 +1,232 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digineo/zackup/config"
+	"github.com/sirupsen/logrus"
+)
+
+// ChangeStats count files and their size.
+type ChangeStats struct {
+	Files uint64
+	Bytes uint64
+}
+
+func (c *ChangeStats) add(size uint64) {
+	c.Files++
+	c.Bytes += size
+}
+
+// DryRunStats summarizes the changes a backup run would transfer below
+// a top-level include path.
+type DryRunStats struct {
+	Path    string
+	Added   ChangeStats
+	Changed ChangeStats
+	Deleted ChangeStats
+}
+
+// DryRun performs a rsync dry run for job, and returns the changes, which
+// a backup run would transfer, grouped by the top-level include paths.
+// Neither a snapshot is created, nor is the state modified. The (local
+// and remote) pre-scripts and the finally-scripts are executed only, if
+// opts.WithHooks is set. They get ZACKUP_DRY_RUN=1 in their environment.
+// Post-scripts, streams and database dumps are never executed.
+func DryRun(job *config.JobConfig, opts RunOptions) ([]DryRunStats, error) {
+	switch typ := job.TransportType(); {
+	case job.IsPush():
+		return nil, fmt.Errorf("dry runs are not supported for pushing hosts")
+	case typ == config.TransportZFSSend:
+		return nil, fmt.Errorf("dry runs are not supported for transport %s", typ)
+	}
+
+	r := newBackupRun(job, opts.Full || state.fullDue(job))
+	r.l = r.l.WithField("dry-run", true)
+	r.dryRun = true
+	if err := r.prepare(time.Now(), 0); err != nil {
+		return nil, err
+	}
+	defer r.release()
+
+	hooks := &hookScripts{env: r.hooks.env, timeout: r.hooks.timeout}
+	if opts.WithHooks {
+		hooks.localPre = r.hooks.localPre
+		hooks.pre = r.hooks.pre
+		hooks.finally = r.hooks.finally
+	}
+	hooks.env = append(hooks.env, "ZACKUP_DRY_RUN=1")
+	r.hooks = hooks
+
+	var out bytes.Buffer
+	err := r.localPre()
+	if err == nil {
+		err = dryTransfer(r.l, job, r.hooks, &r.res, &out)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var includes []string
+	if job.RSync != nil {
+		includes = job.RSync.Included
+	}
+	return parseDryRun(&out, includes, r.ds.Mount)
+}
+
+// dryTransfer is the dry run counterpart of transfer. It connects to
+// the host, runs the remote pre-scripts, rsync (with its output written
+// into out), and the remote finally-scripts. Hosts are neither woken,
+// nor are post-scripts run, or streams and databases dumped.
+func dryTransfer(l *logrus.Entry, job *config.JobConfig, hooks *hookScripts, res *runResult, out io.Writer) error {
+	l.WithField("transport", job.TransportType()).Info("connecting")
+	m := newTransport(job)
+	if err := m.connect(); err != nil {
+		return err
+	}
+	defer m.close()
+
+	remote := func(env []string) func(context.Context, config.ScriptUnit) error {
+		return func(ctx context.Context, u config.ScriptUnit) error {
+			return m.execute(ctx, u.Name, unitScript(u, env))
+		}
+	}
+
+	var err error
+	if len(hooks.pre) > 0 {
+		l.Info("executing pre-scripts")
+		err = runScripts(l, res, hooks.pre, hooks.timeout, remote(hooks.env))
+		if skipRequested(err) {
+			err = errSkipped
+		}
+	}
+
+	if err == nil {
+		err = runRsync(l, m, job, res, "/", out)
+	}
+
+	if len(hooks.finally) > 0 {
+		l.Info("executing finally-scripts")
+		env := append(append([]string{}, hooks.env...), resultEnv(err)...)
+		if finErr := runScripts(l, res, hooks.finally, hooks.timeout, remote(env)); err == nil {
+			err = finErr
+		}
+	}
+	return err
+}
+
+// parseDryRun reads the output of rsync (formatted with
+// config.DryRunFormat), and sums up the changes per include path.
+// Deleted files have no size in the output, their size is taken from
+// the backup in dst.
+func parseDryRun(r io.Reader, includes []string, dst string) ([]DryRunStats, error) {
+	prefixes := includePrefixes(includes)
+	stats := make(map[string]*DryRunStats, len(prefixes))
+	for _, p := range prefixes {
+		stats[p] = &DryRunStats{Path: p}
+	}
+	group := func(name string) *DryRunStats {
+		for _, p := range prefixes { // longest first
+			if p == "/" || name == p || strings.HasPrefix(name, p+"/") {
+				return stats[p]
+			}
+		}
+		s, ok := stats["/"]
+		if !ok {
+			s = &DryRunStats{Path: "/"}
+			stats["/"] = s
+		}
+		return s
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// "YXcstpoguax SIZE NAME", or "*deleting   SIZE NAME"
+		const n = 11
+		if len(line) < n+2 || line[n] != ' ' {
+			continue
+		}
+		deleted := strings.HasPrefix(line, "*deleting")
+		if !deleted && (!strings.ContainsRune("<>ch.", rune(line[0])) || !strings.ContainsRune("fdLDS", rune(line[1]))) {
+			continue // --stats output
+		}
+		if !deleted && (line[1] == 'd' || line[0] == '.') {
+			continue // directories and changed attributes only
+		}
+
+		fields := strings.SplitN(strings.TrimLeft(line[n+1:], " "), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		size, err := strconv.ParseUint(strings.ReplaceAll(fields[0], ",", ""), 10, 64)
+		if err != nil {
+			continue
+		}
+		name := "/" + fields[1]
+		if line[1] == 'L' {
+			name = strings.SplitN(name, " -> ", 2)[0] // symlink target
+		}
+
+		switch {
+		case deleted:
+			if strings.HasSuffix(name, "/") {
+				continue // directory
+			}
+			// rsync reports a size of 0 for deleted files
+			if fi, err := os.Lstat(filepath.Join(dst, name)); err == nil {
+				size = uint64(fi.Size())
+			}
+			group(name).Deleted.add(size)
+		case strings.Trim(line[2:n], "+") == "":
+			group(name).Added.add(size)
+		default:
+			group(name).Changed.add(size)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading rsync output failed: %w", err)
+	}
+
+	list := make([]DryRunStats, 0, len(stats))
+	for _, s := range stats {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list, nil
+}
+
+// includePrefixes returns the static part of each include path, i.e.
+// the directories before the first component containing a wildcard,
+// ordered by descending length.
+func includePrefixes(includes []string) []string {
+	seen := make(map[string]bool, len(includes))
+	list := make([]string, 0, len(includes))
+	for _, incl := range includes {
+		var parts []string
+		for _, part := range strings.Split(strings.Trim(incl, "/"), "/") {
+			if part == "" || strings.ContainsAny(part, "*?[") {
+				break
+			}
+			parts = append(parts, part)
+		}
+		p := "/" + strings.Join(parts, "/")
+		if !seen[p] {
+			seen[p] = true
+			list = append(list, p)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+	return list
+}
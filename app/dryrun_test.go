@@ -0,0 +1,66 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIncludePrefixes(t *testing.T) {
+	t.Parallel()
+
+	actual := includePrefixes([]string{"/etc", "/home/*/docs", "/var/lib/mysql/", "/etc/", "/srv/**"})
+	expected := []string{"/var/lib/mysql", "/home", "/etc", "/srv"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestParseDryRun(t *testing.T) {
+	t.Parallel()
+
+	dst := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dst, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, "etc", "gone"), make([]byte, 42), 0o644); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+
+	out := strings.Join([]string{
+		"cd+++++++++ 4096 etc/",
+		">f+++++++++ 100 etc/new",
+		">f.st...... 1,000 etc/hosts",
+		".f....og... 10 etc/shadow",
+		"cL+++++++++ 3 etc/link -> new",
+		"*deleting   0 etc/gone",
+		"*deleting   0 etc/olddir/",
+		">f+++++++++ 7 home/alice/docs/a b.txt",
+		">f+++++++++ 5 opt/other",
+		"",
+		"Number of files: 4 (reg: 3, dir: 1)",
+		"Total file size: 1,113 bytes",
+	}, "\n")
+
+	actual, err := parseDryRun(strings.NewReader(out), []string{"/etc", "/home/*/docs"}, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []DryRunStats{{
+		Path:  "/",
+		Added: ChangeStats{Files: 1, Bytes: 5},
+	}, {
+		Path:    "/etc",
+		Added:   ChangeStats{Files: 2, Bytes: 103},
+		Changed: ChangeStats{Files: 1, Bytes: 1000},
+		Deleted: ChangeStats{Files: 1, Bytes: 42},
+	}, {
+		Path:  "/home",
+		Added: ChangeStats{Files: 1, Bytes: 7},
+	}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
@@ -0,0 +1,24 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeFilterFile writes the rsync filter rules for host into a merge
+// file in MountBase/.zackup and returns its path. The caller should
+// remove the file after the rsync process has finished.
+func writeFilterFile(host string, rules []string) (string, error) {
+	dir := filepath.Join(MountBase, ".zackup", "filter")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err //nolint:wrapcheck
+	}
+
+	name := filepath.Join(dir, host+".rules")
+	content := strings.Join(rules, "\n") + "\n"
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		return "", err //nolint:wrapcheck
+	}
+	return name, nil
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/digineo/zackup/config"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTree writes files (path relative to the root => content) into a
+// temporary config directory, and returns its path.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		name = filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+		require.NoError(t, os.WriteFile(name, []byte(content), 0o644))
+	}
+	return root
+}
+
+// loadTree writes files (see writeTree) and loads them as config tree.
+func loadTree(t *testing.T, files map[string]string) config.Tree {
+	t.Helper()
+
+	tr := config.NewTree("")
+	require.NoError(t, tr.SetRoot(writeTree(t, files)))
+	return tr
+}
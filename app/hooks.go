@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/digineo/zackup/config"
+	"github.com/sirupsen/logrus"
+)
+
+// hookScripts holds the rendered hook scripts of a run, with all secret
+// references resolved.
+type hookScripts struct {
+	env     []string      // secrets, as "NAME=value"
+	timeout time.Duration // per script unit
+
+	localPre  []config.ScriptUnit
+	pre       []config.ScriptUnit
+	post      []config.ScriptUnit
+	finally   []config.ScriptUnit
+	localPost []config.ScriptUnit
+
+	streams []streamUnit
+}
+
+// SkipExitCode is the exit code with which a pre-script signals that the
+// current run should be skipped (e.g. because the host is busy).
+const SkipExitCode = 99
+
+// errSkipped is returned, if a pre-script has requested to skip the run.
+var errSkipped = errors.New("run skipped by pre-script")
+
+// skipRequested checks whether err is the result of a script exiting
+// with SkipExitCode.
+func skipRequested(err error) bool {
+	var xerr *exec.ExitError
+	return errors.As(err, &xerr) && xerr.ExitCode() == SkipExitCode
+}
+
+// prepareHooks renders the hook scripts of job with the given context,
+// and resolves secret references. The resolved values are returned, for
+// redaction.
+func prepareHooks(job *config.JobConfig, ctx *config.ScriptContext) (*hookScripts, []string, error) {
+	var sec config.Resolver
+	h := &hookScripts{timeout: job.HookTimeout()}
+
+	// resolve secrets at job time, they may have changed since loading
+	var err error
+	if h.env, err = sec.Env(job.Secrets); err != nil {
+		return nil, nil, err //nolint:wrapcheck
+	}
+
+	for _, hook := range []struct {
+		script *config.Script
+		units  *[]config.ScriptUnit
+	}{
+		{&job.LocalPreScript, &h.localPre},
+		{&job.PreScript, &h.pre},
+		{&job.PostScript, &h.post},
+		{&job.FinallyScript, &h.finally},
+		{&job.LocalPostScript, &h.localPost},
+	} {
+		units, err := hook.script.Render(ctx)
+		if err != nil {
+			return nil, nil, err //nolint:wrapcheck
+		}
+		for i := range units {
+			if units[i].Lines, err = sec.Lines(units[i].Lines); err != nil {
+				return nil, nil, err //nolint:wrapcheck
+			}
+		}
+		*hook.units = units
+	}
+
+	for _, s := range job.Streams {
+		u, err := s.Render(ctx)
+		if err != nil {
+			return nil, nil, err //nolint:wrapcheck
+		}
+		if u.Lines, err = sec.Lines(u.Lines); err != nil {
+			return nil, nil, err //nolint:wrapcheck
+		}
+		h.streams = append(h.streams, streamUnit{
+			ScriptUnit: u,
+			file:       s.Name,
+			minSize:    s.MinBytes(),
+			timeout:    s.MaxDuration(),
+		})
+	}
+	return h, sec.Values(), nil
+}
+
+// jobEnv returns the environment variables for local hook scripts.
+func jobEnv(ctx *config.ScriptContext) []string {
+	env := []string{
+		"ZACKUP_HOST=" + ctx.Host,
+		"ZACKUP_DATASET=" + ctx.Dataset,
+		"ZACKUP_TARGET=" + ctx.Target,
+		"ZACKUP_SNAPSHOT=" + ctx.Snapshot,
+		"ZACKUP_STARTED=" + ctx.Started.Format(time.RFC3339),
+		"ZACKUP_ATTEMPT=" + strconv.FormatUint(uint64(ctx.Attempt), 10),
+		"ZACKUP_FULL=" + strconv.FormatBool(ctx.Full),
+	}
+	if ctx.DryRun {
+		env = append(env, "ZACKUP_DRY_RUN=1")
+	}
+	return env
+}
+
+// resultEnv describes the outcome of a run for post and finally hook
+// scripts.
+func resultEnv(err error) []string {
+	switch {
+	case err == nil:
+		return []string{"ZACKUP_RESULT=success", "ZACKUP_ERROR="}
+	case errors.Is(err, errSkipped):
+		return []string{"ZACKUP_RESULT=skipped", "ZACKUP_ERROR="}
+	}
+	return []string{"ZACKUP_RESULT=failure", "ZACKUP_ERROR=" + err.Error()}
+}
+
+// killGroupOnDone kills the process group of cmd (which must have been
+// started with Setpgid), when ctx is done: children of the shell would
+// otherwise keep the output pipes open. Call the returned function after
+// the process has exited.
+func killGroupOnDone(ctx context.Context, cmd *exec.Cmd) func() {
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-exited:
+		}
+	}()
+	return func() { close(exited) }
+}
+
+// execute a script on the backup server:
+//
+//	echo script | env ZACKUP_...=... /bin/sh -esx
+func executeLocal(ctx context.Context, host, name string, script, env []string) error {
+	cmd := exec.Command("/bin/sh", "-esx")
+	cmd.Env = append(os.Environ(), env...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	l := log.WithFields(logrus.Fields{
+		"prefix": "local.execute",
+		"job":    host,
+		"script": name,
+	})
+
+	return pipeScript(ctx, l, "local", cmd, script, nil)
+}
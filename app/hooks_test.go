@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digineo/zackup/config"
+)
+
+func TestExecuteLocal(t *testing.T) {
+	t.Parallel()
+
+	out := filepath.Join(t.TempDir(), "out")
+	started := time.Date(2018, time.December, 9, 13, 0, 0, 0, time.FixedZone("CET", 3600))
+	ds := &dataset{Host: "example.com", Name: "zpool/zackup/example.com"}
+	ctx := &config.ScriptContext{
+		Host:     "example.com",
+		Snapshot: ds.snapshotName(started),
+		Started:  started.UTC(),
+		Attempt:  2,
+		DryRun:   true,
+	}
+	env := append(jobEnv(ctx), resultEnv(errors.New("rsync failed"))...)
+
+	err := executeLocal(context.Background(), "example.com", "test", []string{
+		`echo "$ZACKUP_HOST $ZACKUP_ATTEMPT $ZACKUP_STARTED" > ` + out,
+		`echo "$ZACKUP_RESULT: $ZACKUP_ERROR" >> ` + out,
+		`echo "dry run: $ZACKUP_DRY_RUN" >> ` + out,
+		`echo "$ZACKUP_SNAPSHOT" >> ` + out,
+	}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "example.com 2 2018-12-09T12:00:00Z\nfailure: rsync failed\ndry run: 1\nzpool/zackup/example.com@2018-12-09T12:00:00Z\n"
+	if string(data) != expected {
+		t.Errorf("expected %q, got %q", expected, string(data))
+	}
+
+	err = executeLocal(context.Background(), "example.com", "test", []string{"false", "touch " + out + ".unreachable"}, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "local: unexpected termination") {
+		t.Errorf("expected termination error, got %v", err)
+	}
+	if _, err := os.Stat(out + ".unreachable"); err == nil {
+		t.Error("expected script to abort after failing command")
+	}
+}
+
+func TestSkipRequested(t *testing.T) {
+	t.Parallel()
+
+	err := executeLocal(context.Background(), "example.com", "test", []string{"exit 99"}, nil)
+	if !skipRequested(err) {
+		t.Errorf("expected skip request, got %v", err)
+	}
+	if env := resultEnv(errSkipped); env[0] != "ZACKUP_RESULT=skipped" {
+		t.Errorf("unexpected result env %q", env)
+	}
+
+	err = executeLocal(context.Background(), "example.com", "test", []string{"exit 1"}, nil)
+	if err == nil || skipRequested(err) {
+		t.Errorf("expected regular failure, got %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package app
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // MetricStatus represents the status of a metric set.
 type MetricStatus int
@@ -12,6 +15,8 @@ const (
 	StatusSuccess
 	StatusFailed
 	StatusRunning
+	StatusPaused
+	StatusSkipped
 )
 
 func (s MetricStatus) String() string {
@@ -26,19 +31,44 @@ func (s MetricStatus) String() string {
 		return "failed"
 	case StatusRunning:
 		return "running"
+	case StatusPaused:
+		return "paused"
+	case StatusSkipped:
+		return "skipped"
 	}
 	return fmt.Sprintf("%%!MetricStatus(%d)", s)
 }
 
 func (m *metrics) Status() MetricStatus {
-	t0, tOK, tErr := m.StartedAt, m.SucceededAt, m.FailedAt
+	s := m.runStatus()
+	if s != StatusRunning && m.IsPaused(time.Now()) {
+		return StatusPaused
+	}
+	return s
+}
+
+// IsPaused reports whether scheduled backups are suspended at the given
+// time, either because the host is disabled in its config, or because it
+// was paused with "zackup pause".
+func (m *metrics) IsPaused(t time.Time) bool {
+	if m.Disabled {
+		return true
+	}
+	return m.Paused && (m.PausedUntil == nil || t.Before(*m.PausedUntil))
+}
+
+func (m *metrics) runStatus() MetricStatus {
+	t0, tOK, tErr, tSkip := m.StartedAt, m.SucceededAt, m.FailedAt, m.SkippedAt
 
 	if t0.IsZero() {
 		return StatusPrimed
 	}
-	if (tOK == nil || t0.After(*tOK)) && (tErr == nil || t0.After(*tErr)) {
+	if (tOK == nil || t0.After(*tOK)) && (tErr == nil || t0.After(*tErr)) && (tSkip == nil || t0.After(*tSkip)) {
 		return StatusRunning
 	}
+	if tSkip != nil && (tOK == nil || tSkip.After(*tOK)) && (tErr == nil || tSkip.After(*tErr)) && !tSkip.Before(t0) {
+		return StatusSkipped
+	}
 	if tOK != nil && (tErr == nil || tOK.After(*tErr)) && !tOK.Before(t0) {
 		return StatusSuccess
 	}
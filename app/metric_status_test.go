@@ -60,3 +60,51 @@ func TestMetricsStatus(t *testing.T) {
 		}
 	}
 }
+
+func TestMetricsStatusPaused(t *testing.T) {
+	t.Parallel()
+
+	t1 := time.Now().Add(-time.Hour)
+	past, future := t1.Add(30*time.Minute), t1.Add(2*time.Hour)
+
+	for i, tc := range []struct {
+		subject  metrics
+		expected MetricStatus
+	}{
+		{metrics{Disabled: true}, StatusPaused},
+		{metrics{Paused: true}, StatusPaused},
+		{metrics{Paused: true, PausedUntil: &future}, StatusPaused},
+		{metrics{Paused: true, PausedUntil: &past}, StatusPrimed},
+		{metrics{Paused: true, StartedAt: t1, SucceededAt: &past}, StatusPaused},
+		{metrics{Paused: true, StartedAt: t1}, StatusRunning},
+		{metrics{Disabled: true, StartedAt: t1}, StatusRunning},
+	} {
+		if actual := tc.subject.Status(); actual != tc.expected {
+			t.Errorf("case %d: expected %s, got %s\n", i, tc.expected, actual)
+		}
+	}
+}
+
+func TestMetricsStatusSkipped(t *testing.T) {
+	t.Parallel()
+
+	t1 := time.Date(2018, time.December, 9, 12, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	t3 := t2.Add(time.Hour)
+
+	for i, tc := range []struct {
+		subject  metrics
+		expected MetricStatus
+	}{
+		{metrics{StartedAt: t2, SkippedAt: &t2}, StatusSkipped},
+		{metrics{StartedAt: t2, SkippedAt: &t3, SucceededAt: &t1}, StatusSkipped},
+		{metrics{StartedAt: t2, SkippedAt: &t3, FailedAt: &t1}, StatusSkipped},
+		{metrics{StartedAt: t3, SkippedAt: &t2}, StatusRunning},
+		{metrics{StartedAt: t2, SkippedAt: &t1, SucceededAt: &t3}, StatusSuccess},
+		{metrics{StartedAt: t2, SkippedAt: &t1, FailedAt: &t3}, StatusFailed},
+	} {
+		if actual := tc.subject.Status(); actual != tc.expected {
+			t.Errorf("case %d: expected %s, got %s\n", i, tc.expected, actual)
+		}
+	}
+}
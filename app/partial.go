@@ -0,0 +1,44 @@
+package app
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// partialDir returns the directory in which rsync keeps partially
+// transferred files for the given host. It lives in MountBase/.zackup
+// and hence outside of the host dataset, so partial files never end up
+// in a snapshot.
+func partialDir(host string) string {
+	return filepath.Join(MountBase, ".zackup", "partial", host)
+}
+
+// preparePartialDir creates the partial directory for host (if it does
+// not exist yet) and returns the size of the partial files in bytes,
+// which previous runs have left. rsync only resumes files, which have
+// not changed on the source in the meantime.
+func preparePartialDir(host string) (dir string, size uint64, err error) {
+	dir = partialDir(host)
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return "", 0, err //nolint:wrapcheck
+	}
+
+	err = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		size += uint64(info.Size())
+		return nil
+	})
+	return dir, size, err //nolint:wrapcheck
+}
+
+// cleanPartialDir removes leftovers in the partial directory for host.
+func cleanPartialDir(host string) error {
+	return os.RemoveAll(partialDir(host)) //nolint:wrapcheck
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Pause suspends scheduled backups for the given host until the given
+// time (or indefinitely, if until is zero). The pause is persisted as
+// dataset property, so that it survives restarts and is visible to a
+// running "zackup serve".
+func Pause(host string, until time.Time) error {
+	ds := newDataset(host)
+	if err := ds.create(); err != nil {
+		return err
+	}
+
+	var ts int64
+	var untilp *time.Time
+	if !until.IsZero() {
+		ts = until.Unix()
+		untilp = &until
+	}
+
+	prop := fmt.Sprintf("%s=%d", propZackupPausedUntil, ts)
+	if err := zfs("set", prop, ds.Name); err != nil {
+		return errors.Wrapf(err, "failed to pause %q", host)
+	}
+
+	state.mu.Lock()
+	if m, ok := state.hosts[host]; ok {
+		m.Paused = true
+		m.PausedUntil = untilp
+	}
+	state.mu.Unlock()
+	return nil
+}
+
+// Resume reverts Pause. It does not affect hosts disabled in the config.
+func Resume(host string) error {
+	ds := newDataset(host)
+	if err := zfs("inherit", propZackupPausedUntil, ds.Name); err != nil {
+		return errors.Wrapf(err, "failed to resume %q", host)
+	}
+
+	state.mu.Lock()
+	if m, ok := state.hosts[host]; ok {
+		m.Paused = false
+		m.PausedUntil = nil
+	}
+	state.mu.Unlock()
+	return nil
+}
+
+// HostPaused reports whether the given host is currently disabled or
+// paused.
+func HostPaused(host string) bool {
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	m, ok := state.hosts[host]
+	return ok && m.IsPaused(time.Now())
+}
+
+// refreshPaused re-reads the pause property of all hosts. Pauses may be
+// set or lifted by another zackup process (i.e. "zackup pause" while
+// "zackup serve" is running).
+func (s *State) refreshPaused() {
+	args := []string{
+		"get", "-H", "-p",
+		"-r", "-d", "1",
+		"-t", "filesystem",
+		"-s", "local",
+		"-o", "name,value",
+		propZackupPausedUntil,
+		RootDataset,
+	}
+
+	o, e, err := execZFS(args...)
+	if err != nil {
+		log.WithFields(appendStdlogs(logrus.Fields{
+			logrus.ErrorKey: err,
+			"command":       append([]string{"zfs"}, args...),
+		}, o, e)).Warn("failed to refresh pause state")
+		return
+	}
+
+	paused := make(map[string]string)
+	rds := RootDataset + "/"
+	scan := bufio.NewScanner(o)
+	for scan.Scan() {
+		cols := strings.Split(scan.Text(), "\t")
+		if len(cols) != 2 || !strings.HasPrefix(cols[0], rds) {
+			continue
+		}
+		paused[strings.TrimPrefix(cols[0], rds)] = cols[1]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	decode := propDecoder[propZackupPausedUntil]
+	for host, m := range s.hosts {
+		m.Paused = false
+		m.PausedUntil = nil
+		if val, ok := paused[host]; ok {
+			_ = decode(m, val)
+		}
+	}
+}
@@ -46,6 +46,57 @@ func init() { //nolint:gochecknoinits,funlen
 				return dur
 			},
 		},
+		&promExport{
+			name: "last_skipped",
+			help: "timestamp of last run skipped by a pre-script",
+			typ:  prometheus.CounterValue,
+			value: func(m *HostMetrics) float64 {
+				since := float64(-1)
+				if m.SkippedAt != nil {
+					since = float64(m.SkippedAt.Unix())
+				}
+				return since
+			},
+		},
+		&promExport{
+			name: "last_full",
+			help: "timestamp of last successful full run",
+			typ:  prometheus.CounterValue,
+			value: func(m *HostMetrics) float64 {
+				since := float64(-1)
+				if m.LastFullAt != nil {
+					since = float64(m.LastFullAt.Unix())
+				}
+				return since
+			},
+		},
+		&promExport{
+			name: "incremental_runs",
+			help: "number of successful incremental runs since last full run",
+			typ:  prometheus.GaugeValue,
+			value: func(m *HostMetrics) float64 {
+				return float64(m.IncrementalRuns)
+			},
+		},
+		&promExport{
+			name: "partial_bytes",
+			help: "bytes of partial files found at the start of the last successful run",
+			typ:  prometheus.GaugeValue,
+			value: func(m *HostMetrics) float64 {
+				return float64(m.PartialBytes)
+			},
+		},
+		&promExport{
+			name: "paused",
+			help: "whether scheduled backups are paused or disabled (1) or not (0)",
+			typ:  prometheus.GaugeValue,
+			value: func(m *HostMetrics) float64 {
+				if m.IsPaused(time.Now()) {
+					return 1
+				}
+				return 0
+			},
+		},
 		&promExport{
 			name: "space_used",
 			help: "total space used for backups in bytes",
@@ -0,0 +1,319 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/digineo/zackup/config"
+)
+
+// Commands of the push protocol. The agent connects to the server via
+// SSH, where a forced command ("zackup receive HOST") passes the
+// requested command to ServePush.
+const (
+	pushBegin  = "zackup begin"
+	pushFinish = "zackup finish"
+)
+
+// pushTimeout limits the time between "zackup begin" and "zackup finish".
+// Runs not finished in time are recorded as failure.
+const pushTimeout = 24 * time.Hour
+
+// PushPlan is sent to the agent at the start of a run. It contains
+// everything the agent needs from the merged job config.
+type PushPlan struct {
+	Host    string
+	Started time.Time
+	Attempt uint
+	Full    bool
+	Skipped bool          // a local pre-script has requested to skip the run
+	Env     []string      // secrets, as "NAME=value"
+	Timeout time.Duration // per script unit
+	Pre     []config.ScriptUnit
+	Post    []config.ScriptUnit
+	Finally []config.ScriptUnit
+	Rsync   []string       // rsync arguments, without remote shell, source and destination
+	Scripts []ScriptResult // local pre-scripts, executed by the server
+
+	Snapshot string // name of the snapshot taken after a successful run
+}
+
+// PushReport is sent by the agent at the end of a run.
+type PushReport struct {
+	Started time.Time // from PushPlan
+	Attempt uint      // from PushPlan
+	Full    bool      // from PushPlan
+	Scripts []ScriptResult
+	Skipped bool   // a pre-script has requested to skip the run
+	Error   string // empty on success
+}
+
+func (r *PushReport) err() error {
+	switch {
+	case r.Skipped:
+		return errSkipped
+	case r.Error != "":
+		return fmt.Errorf("agent: %s", r.Error)
+	}
+	return nil
+}
+
+// ServePush handles a command of the push protocol for job, which is
+// read from SSH_ORIGINAL_COMMAND by "zackup receive":
+//
+//   - "zackup begin" starts a run, and writes a PushPlan to out,
+//   - "rsync --server ..." receives the data into the host's dataset,
+//   - "zackup finish" reads a PushReport from in, and completes the run.
+func ServePush(job *config.JobConfig, command string, in io.Reader, out io.Writer) error {
+	if !job.IsPush() {
+		return fmt.Errorf("host %s does not use transport %s", job.Host(), config.TransportPush)
+	}
+
+	switch args := strings.Fields(command); {
+	case command == pushBegin:
+		plan, err := beginPush(job)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(out).Encode(plan) //nolint:wrapcheck
+	case command == pushFinish:
+		var report PushReport
+		if err := json.NewDecoder(in).Decode(&report); err != nil {
+			return fmt.Errorf("invalid report: %w", err)
+		}
+		return finishPush(job, &report)
+	case len(args) > 0 && args[0] == "rsync":
+		// the agent's rsync does not know the start of the run
+		if err := state.pushInProgress(job.Host(), time.Time{}, time.Now()); err != nil {
+			return err
+		}
+		dst := filepath.Join(MountBase, job.Host()) + "/"
+		rsyncArgs, err := rsyncServerArgs(args[1:], dst)
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command(RSyncPath, rsyncArgs...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = in, out, os.Stderr
+		return cmd.Run() //nolint:wrapcheck
+	}
+	return fmt.Errorf("unexpected command %q", command)
+}
+
+// beginPush creates the dataset, records the start of the run, and
+// executes the local pre-scripts, just like PerformBackup.
+func beginPush(job *config.JobConfig) (*PushPlan, error) {
+	r := newBackupRun(job, state.fullDue(job))
+	r.l.Info("creating dataset")
+	if err := r.ds.create(); err != nil {
+		return nil, err
+	}
+
+	// a previous run might have been abandoned by the agent
+	state.expirePush(r.host, time.Now())
+
+	attempt := state.attempt(r.host)
+	err := r.prepare(state.start(r.host), attempt)
+	if err == nil {
+		err = r.localPre()
+	}
+	if err != nil {
+		r.record(err)
+		if errors.Is(err, errSkipped) {
+			return &PushPlan{Host: r.host, Skipped: true}, nil
+		}
+		return nil, err
+	}
+	defer r.release()
+
+	// push hosts are not part of bandwidth groups, see bandwidthLimit
+	opts := config.ArgOptions{
+		Full:           r.res.full,
+		BandwidthLimit: job.Bandwidth.LimitAt(time.Now()),
+	}
+	args := job.RSync.BuildArgVector(config.RsyncSource{}, "", opts)
+
+	r.l.Info("waiting for agent")
+	return &PushPlan{
+		Host:    r.host,
+		Started: r.ctx.Started,
+		Attempt: attempt,
+		Full:    r.res.full,
+		Env:     r.hooks.env,
+		Timeout: r.hooks.timeout,
+		Pre:     r.hooks.pre,
+		Post:    r.hooks.post,
+		Finally: r.hooks.finally,
+		Rsync:   args[:len(args)-2], // source and destination are added by the agent
+		Scripts: r.res.scripts,
+
+		Snapshot: r.ctx.Snapshot,
+	}, nil
+}
+
+// finishPush executes the local post-scripts, creates the snapshot and
+// records the outcome of the run, just like PerformBackup.
+func finishPush(job *config.JobConfig, report *PushReport) error {
+	if err := state.pushInProgress(job.Host(), report.Started, time.Now()); err != nil {
+		return err
+	}
+
+	r := newBackupRun(job, report.Full)
+	r.res.scripts = report.Scripts
+
+	err := r.prepare(report.Started, report.Attempt)
+	if err == nil {
+		err = r.finish(report.err())
+	}
+	r.record(err)
+	return err
+}
+
+// rsyncAllowedOptions are the long options, which the agent's rsync may
+// pass to the server (see server_options() in rsync's options.c).
+// Options naming server side paths (e.g. --temp-dir or --log-file) are
+// rejected.
+var rsyncAllowedOptions = []string{
+	"--server", "--delete", "--delete-before", "--delete-during",
+	"--delete-delay", "--delete-after", "--delete-excluded", "--force",
+	"--numeric-ids", "--ignore-errors", "--partial", "--inplace",
+	"--compress-level", "--log-format", "--fake-super", "--super",
+	"--max-delete", "--max-size", "--min-size", "--modify-window",
+	"--preallocate", "--size-only", "--ignore-existing", "--existing",
+	"--bwlimit", "--no-W",
+}
+
+// rsyncAllowedFlags are the single letter options, which the agent's
+// rsync may pass to the server. Flags changing how the receiver treats
+// existing files and symlinks (e.g. -K, -L, -k, -b or -R) are rejected.
+const rsyncAllowedFlags = "vlrdtpogDHAXESWxczImOJUNiuCyns"
+
+// rsyncBlockSize matches the block size, which is passed as separate
+// argument (--block-size=2048 becomes -B2048).
+var rsyncBlockSize = regexp.MustCompile(`^-B[0-9]+$`)
+
+// pushInProgress checks that the run of host started at the given time
+// is still in progress, i.e. neither finished nor timed out. A zero
+// started time matches any run.
+func (s *State) pushInProgress(host string, started, now time.Time) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.hosts[host]
+	if !ok || m.runStatus() != StatusRunning {
+		if started.IsZero() {
+			return errors.New("no run in progress")
+		}
+		return fmt.Errorf("no run started at %s in progress", started.Format(time.RFC3339))
+	}
+	// the state only knows the start in full seconds
+	if !started.IsZero() && m.StartedAt.Unix() != started.Unix() {
+		return fmt.Errorf("no run started at %s in progress", started.Format(time.RFC3339))
+	}
+	if now.Sub(m.StartedAt) > pushTimeout {
+		return fmt.Errorf("run started at %s has timed out", m.StartedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// expirePush records a failure for a run of host, which was begun more
+// than pushTimeout ago, but never finished.
+func (s *State) expirePush(host string, now time.Time) {
+	s.mu.RLock()
+	m, ok := s.hosts[host]
+	expired := ok && m.runStatus() == StatusRunning && now.Sub(m.StartedAt) > pushTimeout
+	s.mu.RUnlock()
+
+	if expired {
+		log.WithField("job", host).Warn("pushed run was not finished in time")
+		s.failure(host, runResult{})
+	}
+}
+
+// refreshPushed re-reads the state of hosts pushed by an agent. Their
+// runs are recorded by "zackup receive", i.e. by another process than
+// "zackup serve".
+func (s *State) refreshPushed(now time.Time) {
+	s.mu.RLock()
+	var hosts []string
+	for host, m := range s.hosts {
+		if m.job != nil && m.job.IsPush() {
+			hosts = append(hosts, host)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, host := range hosts {
+		props, err := readHost(host)
+		if err != nil {
+			continue // already logged
+		}
+		s.mu.Lock()
+		if m, ok := s.hosts[host]; ok {
+			m.apply(props)
+		}
+		s.mu.Unlock()
+
+		s.expirePush(host, now)
+	}
+}
+
+// rsyncServerArgs validates the arguments of the agent's "rsync --server"
+// command, and replaces the destination with dst. Symlinks are munged on
+// the server (--munge-links), so that a compromised agent cannot write
+// outside of dst through a symlink it has planted in a previous run.
+func rsyncServerArgs(args []string, dst string) ([]string, error) {
+	if len(args) < 3 || args[0] != "--server" {
+		return nil, errors.New("expected rsync --server")
+	}
+
+	n := len(args) - 2
+	for _, arg := range args[:n] {
+		name := arg
+		if i := strings.IndexByte(arg, '='); i > 0 {
+			name = arg[:i]
+		}
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			if !contains(rsyncAllowedOptions, name) {
+				return nil, fmt.Errorf("rsync option %s is not allowed", name)
+			}
+		case rsyncBlockSize.MatchString(arg):
+		case strings.HasPrefix(arg, "-"):
+			// short options may be followed by the protocol capabilities
+			// ("-logDtpre.iLsfxC"), which may contain any letter
+			flags := arg[1:]
+			if i := strings.Index(flags, "e."); i >= 0 {
+				flags = flags[:i]
+			}
+			if flags == "" || strings.Trim(flags, rsyncAllowedFlags) != "" {
+				return nil, fmt.Errorf("rsync option %s is not allowed", arg)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected rsync argument %q", arg)
+		}
+	}
+	if args[n] != "." {
+		return nil, fmt.Errorf("unexpected rsync argument %q", args[n])
+	}
+
+	list := make([]string, 0, n+3)
+	list = append(list, args[:n]...)
+	return append(list, "--munge-links", ".", dst), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
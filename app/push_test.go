@@ -0,0 +1,170 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/digineo/zackup/config"
+)
+
+func TestRsyncServerArgs(t *testing.T) {
+	t.Parallel()
+
+	const dst = "/zackup/laptop/"
+	args, err := rsyncServerArgs(strings.Fields("--server -vlogDtprHe.iLsfxCIvu -B2048 --delete --numeric-ids . /"), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Fields("--server -vlogDtprHe.iLsfxCIvu -B2048 --delete --numeric-ids --munge-links . /zackup/laptop/")
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+
+	for cmd, msg := range map[string]string{
+		"--sender -logDtpre.iLsfxC . /":          "expected rsync --server",
+		"--server --sender -logDtpre.iLsfxC . /": "rsync option --sender is not allowed",
+		"--server --log-file=/etc/passwd . /":    "rsync option --log-file is not allowed",
+		"--server -logDtprT /tmp e.iLsfxC . /":   "rsync option -logDtprT is not allowed",
+		"--server -logDtprKe.iLsfxC . /":         "rsync option -logDtprKe.iLsfxC is not allowed",
+		"--server -Le.iLsfxC . /":                "rsync option -Le.iLsfxC is not allowed",
+		"--server -rbe.iLsfxC . /":               "rsync option -rbe.iLsfxC is not allowed",
+		"--server --keep-dirlinks . /":           "rsync option --keep-dirlinks is not allowed",
+		"--server -B2048x . /":                   "rsync option -B2048x is not allowed",
+		"--server -logDtpre.iLsfxC /etc . /":     `unexpected rsync argument "/etc"`,
+		"--server -logDtpre.iLsfxC /etc /":       `unexpected rsync argument "/etc"`,
+		"--server -logDtpre.iLsfxC":              "expected rsync --server",
+	} {
+		if _, err := rsyncServerArgs(strings.Fields(cmd), dst); err == nil || err.Error() != msg {
+			t.Errorf("%s: expected error %q, got %v", cmd, msg, err)
+		}
+	}
+}
+
+func TestServePushRequiresPushTransport(t *testing.T) {
+	t.Parallel()
+
+	err := ServePush(&config.JobConfig{}, pushBegin, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "does not use transport push") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPushTimeout(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	started := now.Add(-pushTimeout - time.Minute)
+	job := &config.JobConfig{Transport: &config.TransportConfig{Type: config.TransportPush}}
+	s := &State{
+		hosts: map[string]*metrics{
+			"laptop": {job: job, StartedAt: started},
+		},
+		mu: &sync.RWMutex{},
+	}
+
+	if err := s.pushInProgress("laptop", started, started.Add(time.Hour)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := s.pushInProgress("laptop", time.Time{}, started.Add(time.Hour)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err := s.pushInProgress("laptop", started.Add(time.Minute), started.Add(time.Hour))
+	if err == nil || !strings.Contains(err.Error(), "no run started") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = s.pushInProgress("laptop", started, now)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	s.refreshPushed(now)
+	if m := s.hosts["laptop"]; m.FailedAt == nil || m.Failures != 1 {
+		t.Errorf("expected abandoned run to be recorded as failure, got %+v", m)
+	}
+
+	err = s.pushInProgress("laptop", started, now)
+	if err == nil || !strings.Contains(err.Error(), "no run started") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = s.pushInProgress("laptop", time.Time{}, now)
+	if err == nil || err.Error() != "no run in progress" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestRunAgent runs the agent against fake ssh and rsync commands, which
+// log their arguments and stdin.
+func TestRunAgent(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "log")
+
+	plan := PushPlan{
+		Host:    "laptop",
+		Started: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		Attempt: 1,
+		Env:     []string{"SECRET=s3cr3t"},
+		Timeout: time.Minute,
+		Pre:     []config.ScriptUnit{{Name: "pre", Lines: []string{`echo "pre $SECRET" >> ` + logFile}}},
+		Finally: []config.ScriptUnit{{Name: "finally", Lines: []string{`echo "finally $ZACKUP_RESULT" >> ` + logFile}}},
+		Rsync:   []string{"--numeric-ids"},
+		Scripts: []ScriptResult{{Name: "local_pre"}},
+	}
+	planJSON, err := json.Marshal(&plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeScript := func(name, body string) string {
+		name = filepath.Join(dir, name)
+		if err := os.WriteFile(name, []byte("#!/bin/sh\n"+body), 0o755); err != nil { //nolint:gosec
+			t.Fatal(err)
+		}
+		return name
+	}
+	ssh := writeScript("ssh", `
+for last; do :; done
+case "$last" in
+"zackup begin") echo '`+string(planJSON)+`' ;;
+"zackup finish") cat > `+filepath.Join(dir, "report")+` ;;
+esac
+`)
+	rsync := writeScript("rsync", `echo "rsync $*" >> `+logFile+"\n")
+
+	oldSSH, oldRsync := SSHPath, RSyncPath
+	SSHPath, RSyncPath = ssh, rsync
+	defer func() { SSHPath, RSyncPath = oldSSH, oldRsync }()
+
+	if err := RunAgent(AgentOptions{Server: "zackup@backup", Port: 2222}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(logFile)
+	expected := "pre s3cr3t\n" +
+		"rsync --numeric-ids -e " + ssh + " -oBatchMode=yes -p 2222 / zackup@backup:/\n" +
+		"finally success\n"
+	if string(data) != expected {
+		t.Errorf("expected log %q, got %q", expected, data)
+	}
+
+	var report PushReport
+	data, _ = os.ReadFile(filepath.Join(dir, "report"))
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if !report.Started.Equal(plan.Started) || report.Attempt != 1 || report.Error != "" || report.Skipped {
+		t.Errorf("unexpected report %+v", report)
+	}
+	var names []string
+	for _, s := range report.Scripts {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, ",") != "local_pre,pre,finally" {
+		t.Errorf("unexpected scripts %v", names)
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/digineo/zackup/config"
 )
@@ -18,7 +19,11 @@ const jobQueueSize = 16
 type Queue interface {
 	// Enqueue adds a job to the queue. The job is run immediately if the
 	// queue is empty. This method may block if a backlog has accumulated.
-	Enqueue(job *config.JobConfig)
+	Enqueue(job *config.JobConfig, opts RunOptions)
+
+	// EnqueueCheck adds a check of job (see CheckHost) to the queue. The
+	// report is passed to done, once the check has finished.
+	EnqueueCheck(job *config.JobConfig, done func(*HostReport))
 
 	// Resize changes the size of the queue. When sizing down, surplus
 	// running jobs will finish. Values for newSize are capped; for values
@@ -32,9 +37,17 @@ type Queue interface {
 
 type quitCh chan struct{}
 
+// queueItem is a job with its run options. If check is set, the job is
+// checked instead of backed up.
+type queueItem struct {
+	job   *config.JobConfig
+	opts  RunOptions
+	check func(*HostReport)
+}
+
 type queue struct {
 	workers     []quitCh
-	jobs        chan *config.JobConfig
+	jobs        chan queueItem
 	workerGroup sync.WaitGroup
 	jobGroup    sync.WaitGroup
 
@@ -46,7 +59,7 @@ type queue struct {
 func NewQueue() Queue {
 	q := queue{
 		workers: make([]quitCh, 0, maxParallelity),
-		jobs:    make(chan *config.JobConfig, jobQueueSize),
+		jobs:    make(chan queueItem, jobQueueSize),
 	}
 
 	q.workerGroup.Add(1)
@@ -65,8 +78,12 @@ func (q *queue) newWorker() {
 	Loop:
 		for {
 			select {
-			case job := <-q.jobs:
-				PerformBackup(job)
+			case item := <-q.jobs:
+				if item.check != nil {
+					item.check(CheckHost(item.job))
+				} else {
+					PerformBackup(item.job, item.opts)
+				}
 				q.jobGroup.Done()
 			case <-quit:
 				break Loop
@@ -76,9 +93,14 @@ func (q *queue) newWorker() {
 	}()
 }
 
-func (q *queue) Enqueue(job *config.JobConfig) {
+func (q *queue) Enqueue(job *config.JobConfig, opts RunOptions) {
+	q.jobGroup.Add(1)
+	q.jobs <- queueItem{job: job, opts: opts}
+}
+
+func (q *queue) EnqueueCheck(job *config.JobConfig, done func(*HostReport)) {
 	q.jobGroup.Add(1)
-	q.jobs <- job
+	q.jobs <- queueItem{job: job, check: done}
 }
 
 func (q *queue) Wait() {
@@ -86,7 +108,7 @@ func (q *queue) Wait() {
 }
 
 func (q *queue) Resize(newSize int) {
-	if newSize < 0 {
+	if newSize < 1 {
 		newSize = 1
 	} else if newSize > maxParallelity {
 		newSize = maxParallelity
@@ -95,6 +117,7 @@ func (q *queue) Resize(newSize int) {
 	q.Lock()
 	defer q.Unlock()
 
+	atomic.StoreInt32(&parallelity, int32(newSize))
 	diff := len(q.workers) - newSize
 
 	if diff > 0 {
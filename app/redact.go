@@ -0,0 +1,127 @@
+package app
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/digineo/zackup/config"
+)
+
+// redacted replaces secret values in log output.
+const redacted = "[redacted]"
+
+// secretRegistry tracks the resolved secret values of all running jobs.
+type secretRegistry struct {
+	values   map[string]int // value => number of jobs using it
+	replacer *strings.Replacer
+
+	sync.RWMutex
+}
+
+var secrets = &secretRegistry{values: make(map[string]int)}
+
+// Redact replaces the secret values of all running jobs in s. It is
+// safe to use concurrently, e.g. as graylog redactor.
+func Redact(s string) string {
+	return secrets.redact(s)
+}
+
+// add registers values for redaction. The returned function removes
+// them again. Output is redacted line by line, hence each line of a
+// multi-line value (e.g. a PEM key) is registered on its own.
+func (r *secretRegistry) add(values []string) func() {
+	values = secretLines(values)
+	if len(values) == 0 {
+		return func() {}
+	}
+
+	r.Lock()
+	for _, v := range values {
+		r.values[v]++
+	}
+	r.rebuild()
+	r.Unlock()
+
+	return func() {
+		r.Lock()
+		for _, v := range values {
+			if r.values[v]--; r.values[v] <= 0 {
+				delete(r.values, v)
+			}
+		}
+		r.rebuild()
+		r.Unlock()
+	}
+}
+
+// secretLines returns values, and the non-empty lines of multi-line
+// values.
+func secretLines(values []string) []string {
+	lines := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			lines = append(lines, v)
+		}
+		if !strings.ContainsAny(v, "\r\n") {
+			continue
+		}
+		for _, line := range strings.FieldsFunc(v, func(c rune) bool { return c == '\r' || c == '\n' }) {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+// unsafe, caller must lock r.
+func (r *secretRegistry) rebuild() {
+	if len(r.values) == 0 {
+		r.replacer = nil
+		return
+	}
+
+	// replace longer values first, in case one secret contains another
+	values := make([]string, 0, len(r.values))
+	for v := range r.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, redacted)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+func (r *secretRegistry) redact(s string) string {
+	r.RLock()
+	defer r.RUnlock()
+
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// withEnv prefixes script with export statements for the given
+// "NAME=value" pairs. Tracing is disabled while exporting, so the
+// values don't show up in the output of "sh -x".
+func withEnv(env, script []string) []string {
+	if len(env) == 0 {
+		return script
+	}
+
+	lines := make([]string, 0, len(env)+len(script)+2)
+	lines = append(lines, "set +x")
+	for _, kv := range env {
+		i := strings.IndexByte(kv, '=')
+		lines = append(lines, "export "+kv[:i]+"="+config.ShellQuote(kv[i+1:]))
+	}
+	lines = append(lines, "set -x")
+	return append(lines, script...)
+}
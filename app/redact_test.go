@@ -0,0 +1,75 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSecretRegistry(t *testing.T) {
+	t.Parallel()
+
+	r := &secretRegistry{values: make(map[string]int)}
+	if got := r.redact("password=foo"); got != "password=foo" {
+		t.Errorf("expected no redaction, got %q", got)
+	}
+
+	remove1 := r.add([]string{"foo", "foobar"})
+	remove2 := r.add([]string{"foo"})
+	if got, want := r.redact("x=foobar y=foo"), "x=[redacted] y=[redacted]"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	remove1()
+	if got, want := r.redact("x=foobar y=foo"), "x=[redacted]bar y=[redacted]"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	remove2()
+	if got := r.redact("foo"); got != "foo" {
+		t.Errorf("expected no redaction, got %q", got)
+	}
+}
+
+func TestSecretRegistryMultiLine(t *testing.T) {
+	t.Parallel()
+
+	r := &secretRegistry{values: make(map[string]int)}
+	remove := r.add([]string{"-----BEGIN KEY-----\nMIIEvQIBADAN\r\nBgkqhkiG9w0B\n\n-----END KEY-----\n"})
+
+	// output is logged line by line
+	for line, want := range map[string]string{
+		"+ echo MIIEvQIBADAN": "+ echo [redacted]",
+		"BgkqhkiG9w0B":        "[redacted]",
+		"-----END KEY-----":   "[redacted]",
+		"no secret in here":   "no secret in here",
+	} {
+		if got := r.redact(line); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+
+	remove()
+	if len(r.values) != 0 || r.replacer != nil {
+		t.Errorf("expected empty registry, got %v", r.values)
+	}
+}
+
+func TestWithEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := withEnv([]string{"A=it's", "B=x=y"}, []string{"echo $A"})
+	expected := []string{
+		"set +x",
+		`export A='it'\''s'`,
+		"export B='x=y'",
+		"set -x",
+		"echo $A",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+
+	if actual := withEnv(nil, []string{"true"}); !reflect.DeepEqual(actual, []string{"true"}) {
+		t.Errorf("expected unchanged script, got %q", actual)
+	}
+}
@@ -2,12 +2,16 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"time"
 
 	"github.com/digineo/zackup/config"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
@@ -26,60 +30,303 @@ func newDataset(host string) *dataset {
 	}
 }
 
+// RunOptions modify the behaviour of a single backup run.
+type RunOptions struct {
+	// Full forces a full run (rsync --checksum), regardless of the
+	// job's FullEvery setting.
+	Full bool
+
+	// WithHooks enables the hook scripts in dry runs (see DryRun).
+	WithHooks bool
+}
+
+// runResult collects details of a run, which are recorded in the state.
+type runResult struct {
+	full    bool           // whether this was a full run
+	partial uint64         // bytes of partial files left by previous runs
+	scripts []ScriptResult // executed hook scripts, in order of execution
+	streams []StreamResult // written stream and database dump files
+}
+
 // PerformBackup executes the backup job.
-func PerformBackup(job *config.JobConfig) {
-	host := job.Host()
-	l := log.WithField("job", host)
+func PerformBackup(job *config.JobConfig, opts RunOptions) {
+	if job.IsPush() {
+		log.WithField("job", job.Host()).Warn("host pushes its backups with zackup agent, skipping")
+		return
+	}
+
+	r := newBackupRun(job, opts.Full || state.fullDue(job))
 	var err error
 
-	l.Info("creating dataset")
-	ds := newDataset(host)
-	if err = ds.create(); err != nil {
+	r.l.Info("creating dataset")
+	if err = r.ds.create(); err != nil {
 		return
 	}
 
 	// requires dataset to exist
-	defer func() {
+	defer func() { r.record(err) }()
+	attempt := state.attempt(r.host)
+	if err = r.prepare(state.start(r.host), attempt); err != nil {
+		return
+	}
+	if err = r.localPre(); err != nil {
+		return
+	}
+
+	err = transfer(r.l, job, r.hooks, r.ctx.Started, &r.res)
+	err = r.finish(err)
+}
+
+// backupRun holds the state of a single run of a job, from the local
+// pre-scripts to the state bookkeeping.
+type backupRun struct {
+	job  *config.JobConfig
+	host string
+	l    *logrus.Entry
+	ds   *dataset
+	res  runResult
+
+	dryRun  bool                  // see DryRun()
+	ctx     *config.ScriptContext // set by prepare()
+	hooks   *hookScripts          // set by prepare()
+	env     []string              // for local scripts, set by prepare()
+	release func()                // removes the secrets from the redactor
+}
+
+func newBackupRun(job *config.JobConfig, full bool) *backupRun {
+	host := job.Host()
+	return &backupRun{
+		job:  job,
+		host: host,
+		l: log.WithFields(logrus.Fields{
+			"job":  host,
+			"type": runType(full),
+		}),
+		ds:      newDataset(host),
+		res:     runResult{full: full},
+		release: func() {},
+	}
+}
+
+// prepare renders the hook scripts for a run started at the given time.
+func (r *backupRun) prepare(started time.Time, attempt uint) error {
+	r.ctx = &config.ScriptContext{
+		Host:     r.host,
+		Dataset:  r.ds.Name,
+		Target:   r.ds.Mount,
+		Snapshot: r.ds.snapshotName(started),
+		Started:  started,
+		Attempt:  attempt,
+		Full:     r.res.full,
+		DryRun:   r.dryRun,
+	}
+	if r.job.RSync != nil {
+		r.ctx.Include = r.job.RSync.Included
+	}
+
+	hooks, values, err := prepareHooks(r.job, r.ctx)
+	if err != nil {
+		return err
+	}
+	r.hooks = hooks
+	r.release = secrets.add(values)
+	r.env = append(jobEnv(r.ctx), hooks.env...)
+	return nil
+}
+
+// local executes script units on the backup server.
+func (r *backupRun) local(env []string) func(context.Context, config.ScriptUnit) error {
+	return func(ctx context.Context, u config.ScriptUnit) error {
+		return executeLocal(ctx, r.host, u.Name, unitScript(u, nil), env)
+	}
+}
+
+// localPre runs the local pre-scripts.
+func (r *backupRun) localPre() error {
+	if len(r.hooks.localPre) == 0 {
+		return nil
+	}
+	r.l.Info("executing local pre-scripts")
+	err := runScripts(r.l, &r.res, r.hooks.localPre, r.hooks.timeout, r.local(r.env))
+	if skipRequested(err) {
+		err = errSkipped
+	}
+	return err
+}
+
+// localPost runs the local post-scripts with the outcome of the
+// transfer (err). It returns err, or the error of the scripts.
+func (r *backupRun) localPost(err error) error {
+	if len(r.hooks.localPost) > 0 {
+		r.l.Info("executing local post-scripts")
+		postEnv := append(append([]string{}, r.env...), resultEnv(err)...)
+		postErr := runScripts(r.l, &r.res, r.hooks.localPost, r.hooks.timeout, r.local(postEnv))
 		if err == nil {
-			l.Info("backup succeeded")
-			state.success(host)
-			return
+			err = postErr
+		}
+	}
+	return err
+}
+
+// finish runs the local post-scripts with the outcome of the transfer
+// (err), and creates the snapshot, if everything went fine.
+func (r *backupRun) finish(err error) error {
+	if err = r.localPost(err); err != nil {
+		return err
+	}
+
+	r.l.WithField("snapshot", r.ctx.Snapshot).Info("creating snapshot")
+	return r.ds.snapshot(r.ctx.Snapshot, &r.res)
+}
+
+// record stores the outcome of the run in the state.
+func (r *backupRun) record(err error) {
+	defer r.release()
+
+	if errors.Is(err, errSkipped) {
+		r.l.Info("backup skipped")
+		state.skipped(r.host, r.res)
+		return
+	}
+	if err == nil {
+		r.l.Info("backup succeeded")
+		state.success(r.host, r.res)
+		// also removes leftovers from before resume was disabled
+		if err := cleanPartialDir(r.host); err != nil {
+			r.l.WithError(err).Warn("failed to remove partial files")
 		}
-		l.WithError(err).Error("backup failed")
-		state.failure(host)
-	}()
-	state.start(host)
-
-	l.Info("establishing SSH tunnel")
-	m := newSSHMaster(host, job.SSH)
-	if err = m.connect(); err != nil {
 		return
 	}
+	r.l.WithError(err).Error("backup failed")
+	state.failure(r.host, r.res)
+}
+
+// transfer wakes the host (if configured), and runs the remote
+// pre-scripts, rsync (from a source snapshot, if configured) or zfs send,
+// the streams, the database dumps and the remote post-scripts.
+// Once the pre-scripts have been started, the finally-scripts are run, too.
+// A host woken by zackup is shut down afterwards, if configured.
+func transfer(l *logrus.Entry, job *config.JobConfig, hooks *hookScripts, started time.Time, res *runResult) error {
+	host := job.Host()
+
+	woken := false
+	if w := job.WakeOnLAN; w != nil {
+		var err error
+		if woken, err = wakeHost(l, w, job.ProbeAddress(), tcpProbe, wakePollInterval); err != nil {
+			return err
+		}
+	}
+
+	l.WithField("transport", job.TransportType()).Info("connecting")
+	m := newTransport(job)
+	if err := m.connect(); err != nil {
+		return err
+	}
 	defer m.close()
 
-	if script := job.PreScript.Lines(); len(script) > 0 {
+	remote := func(env []string) func(context.Context, config.ScriptUnit) error {
+		return func(ctx context.Context, u config.ScriptUnit) error {
+			return m.execute(ctx, u.Name, unitScript(u, env))
+		}
+	}
+
+	var err error
+	if len(hooks.pre) > 0 {
 		l.Info("executing pre-scripts")
-		if err = m.execute(script); err != nil {
-			return
+		err = runScripts(l, res, hooks.pre, hooks.timeout, remote(hooks.env))
+		if skipRequested(err) {
+			err = errSkipped
 		}
 	}
 
-	l.Info("starting rsync")
-	if err = m.rsync(job.RSync); err != nil {
-		return
+	if err == nil {
+		if job.TransportType() == config.TransportZFSSend {
+			err = runZFSSend(l, m, job, config.ZFSSendName(started), hooks.timeout, res)
+		} else if snap := job.SourceSnapshot; snap != nil {
+			err = withSourceSnapshot(l, res, snap, job.RSync, hooks.timeout, remote(nil), func(src string) error {
+				return runRsync(l, m, job, res, src, nil)
+			})
+		} else {
+			err = runRsync(l, m, job, res, "/", nil)
+		}
+	}
+
+	if err == nil && len(hooks.streams) > 0 {
+		l.Info("executing streams")
+		err = runStreams(l, m, host, hooks, res)
 	}
 
-	if script := job.PostScript.Lines(); len(script) > 0 {
+	if err == nil && len(job.Databases) > 0 {
+		l.Info("dumping databases")
+		err = runDatabases(l, m, host, job.Databases, hooks, res)
+	}
+
+	if err == nil && len(hooks.post) > 0 {
 		l.Info("executing post-scripts")
-		if err = m.execute(script); err != nil {
-			return
+		err = runScripts(l, res, hooks.post, hooks.timeout, remote(hooks.env))
+	}
+
+	if len(hooks.finally) > 0 {
+		l.Info("executing finally-scripts")
+		env := append(append([]string{}, hooks.env...), resultEnv(err)...)
+		if finErr := runScripts(l, res, hooks.finally, hooks.timeout, remote(env)); err == nil {
+			err = finErr
 		}
 	}
 
-	l.Info("creating snapshot")
-	if err = ds.snapshot(); err != nil {
-		return
+	if woken && job.WakeOnLAN.Shutdown != "" {
+		l.Info("shutting down host")
+		shutdown := []config.ScriptUnit{{Name: "wake_on_lan.shutdown", Lines: []string{job.WakeOnLAN.Shutdown}}}
+		if shutErr := runScripts(l, res, shutdown, hooks.timeout, remote(hooks.env)); shutErr != nil {
+			l.WithError(shutErr).Warn("shutdown failed")
+		}
 	}
+	return err
+}
+
+// runRsync prepares the rsync options and runs rsync, copying from the
+// given source path on the remote host. If out is not nil, rsync only
+// performs a dry run, and writes its itemized output into out.
+func runRsync(l *logrus.Entry, m transport, job *config.JobConfig, res *runResult, src string, out io.Writer) error {
+	host := job.Host()
+
+	bwlimit, release := bandwidthLimit(state.tree, job, time.Now())
+	defer release()
+
+	var err error
+	argOpts := config.ArgOptions{
+		Full:           res.full,
+		BandwidthLimit: bwlimit,
+		DryRun:         out != nil,
+	}
+	if argOpts.BandwidthLimit > 0 {
+		l.WithField("bwlimit", argOpts.BandwidthLimit.String()).Info("limiting bandwidth")
+	}
+	if job.RSync.ResumeEnabled() && !argOpts.DryRun {
+		if argOpts.PartialDir, res.partial, err = preparePartialDir(host); err != nil {
+			return err
+		}
+		if res.partial > 0 {
+			l.WithField("partial", humanize.Bytes(res.partial)).Info("found partial files of previous runs")
+		}
+	}
+
+	rules := job.RSync.FilterRules()
+	if len(job.Streams) > 0 || len(job.Databases) > 0 {
+		// keep the previous stream files, rsync's --delete would remove them
+		rules = append([]string{"P /" + config.StreamsDir + "/"}, rules...)
+	}
+	if argOpts.FilterFile, err = writeFilterFile(host, rules); err != nil {
+		return err
+	}
+	l.WithField("filter", argOpts.FilterFile).Debug("wrote rsync filter rules")
+
+	l.Info("starting rsync")
+	err = m.rsync(job.RSync, src, argOpts, out)
+	if rmErr := os.Remove(argOpts.FilterFile); rmErr != nil {
+		l.WithError(rmErr).Warn("failed to remove rsync filter rules")
+	}
+	return err
 }
 
 // zfs create -p ds.Name.
@@ -90,17 +337,29 @@ func (ds *dataset) create() error {
 	return nil
 }
 
-// zfs snapshot ds.Name@time.RFC3339.
-func (ds *dataset) snapshot() error {
-	now := time.Now().UTC()
-	name := fmt.Sprintf("%s@%s", ds.Name, now.Format(time.RFC3339))
+// snapshotName returns ds.Name@time.RFC3339 for a run started at t.
+func (ds *dataset) snapshotName(t time.Time) string {
+	return fmt.Sprintf("%s@%s", ds.Name, t.UTC().Format(time.RFC3339))
+}
+
+// zfs snapshot -o type=(full|incremental) -o scripts=... name.
+func (ds *dataset) snapshot(name string, res *runResult) error {
+	typ := fmt.Sprintf("%s=%s", propZackupRunType, runType(res.full))
+	scripts := fmt.Sprintf("%s=%s", propZackupScripts, encodeScripts(res.scripts))
 
-	if err := zfs("snapshot", name); err != nil {
+	if err := zfs("snapshot", "-o", typ, "-o", scripts, name); err != nil {
 		return errors.Wrapf(err, "failed to zfs snapshot %q", name)
 	}
 	return nil
 }
 
+func runType(full bool) string {
+	if full {
+		return "full"
+	}
+	return "incremental"
+}
+
 func zfs(args ...string) error {
 	o, e, err := execZFS(args...)
 	if err != nil {
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"context"
+	"net"
 	"sync"
 	"time"
 
@@ -17,10 +19,22 @@ type Scheduler interface {
 	Stop()
 }
 
+// Opportunistic hosts are probed every probeInterval (TCP connect with
+// probeTimeout), while their last success is older than their desired
+// interval. After a failed run, they are retried after retryDelay.
+const (
+	probeInterval = 5 * time.Minute
+	probeTimeout  = 5 * time.Second
+	retryDelay    = time.Hour
+)
+
 type scheduler struct {
 	queue  Queue
 	logger *logrus.Entry
 
+	probe  func(ctx context.Context, addr string) error
+	probed map[string]time.Time // last probe of opportunistic hosts
+
 	quit chan struct{} // interrupts loop in Start()
 	stop bool          // interrupts loop in run()
 	wg   sync.WaitGroup
@@ -35,6 +49,8 @@ func NewScheduler(queue Queue) Scheduler {
 	sch := &scheduler{
 		queue:  queue,
 		logger: log.WithField("prefix", "scheduler"),
+		probe:  tcpProbe,
+		probed: make(map[string]time.Time),
 	}
 
 	sch.wg.Add(1)
@@ -74,19 +90,24 @@ func (sch *scheduler) run() {
 	sch.Lock()
 	defer sch.Unlock()
 
-	for host, job := range state.hosts {
+	// pauses might have changed by another process
+	state.refreshPaused()
+
+	// pushed runs are recorded by "zackup receive"
+	state.refreshPushed(time.Now())
+
+	hosts := state.snapshot()
+
+	var candidates []string
+	for host, job := range hosts {
 		if sch.stop {
 			// abort early if Stop() was called
 			return
 		}
-		if job == nil {
-			// safety-net
-			continue
-		}
 
 		now := time.Now()
 		if job.ScheduledAt.IsZero() {
-			state.reschedule(host, now)
+			job.ScheduledAt = state.reschedule(host, now)
 		}
 
 		s := job.Status()
@@ -95,16 +116,98 @@ func (sch *scheduler) run() {
 			"scheduled-at": job.ScheduledAt.Format(time.RFC3339),
 			"status":       s.String(),
 		})
-		if s == StatusRunning || job.ScheduledAt.After(now) {
-			l.Debug("ignore active or planned jobs")
+		if s == StatusRunning {
+			l.Debug("ignore active jobs")
+			continue
+		}
+		if job.removed {
+			l.Debug("ignore removed jobs")
+			continue
+		}
+		if s == StatusPaused {
+			l.Debug("ignore paused jobs")
+			continue
+		}
+		if job.job != nil && job.job.IsPush() {
+			l.Debug("ignore jobs pushed by an agent")
+			continue
+		}
+		if job.job != nil && job.job.IsOpportunistic() {
+			if job.opportunisticDue(now) && now.Sub(sch.probed[host]) >= probeInterval {
+				candidates = append(candidates, host)
+			}
+			continue
+		}
+		if job.ScheduledAt.After(now) {
+			l.Debug("ignore planned jobs")
 			continue
 		}
 
 		// this might block if backlog is full
 		l.Info("enqueueing job")
-		sch.queue.Enqueue(job.job)
+		sch.queue.Enqueue(job.job, RunOptions{})
 
 		l.Info("rescheduleing job")
 		state.reschedule(host, time.Now())
 	}
+
+	sch.runOpportunistic(hosts, candidates)
+}
+
+// runOpportunistic probes the given opportunistic hosts in parallel, and
+// enqueues those which are reachable. Unreachable hosts are not marked
+// as failed.
+func (sch *scheduler) runOpportunistic(hosts map[string]metrics, candidates []string) {
+	reachable := make([]bool, len(candidates))
+	var wg sync.WaitGroup
+	for i, host := range candidates {
+		sch.probed[host] = time.Now()
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+			defer cancel()
+			reachable[i] = sch.probe(ctx, addr) == nil
+		}(i, hosts[host].job.ProbeAddress())
+	}
+	wg.Wait()
+
+	for i, host := range candidates {
+		if sch.stop {
+			return
+		}
+		l := sch.logger.WithFields(logrus.Fields{
+			"job":   host,
+			"probe": hosts[host].job.ProbeAddress(),
+		})
+		if !reachable[i] {
+			l.Debug("opportunistic job not reachable")
+			continue
+		}
+		l.Info("enqueueing opportunistic job")
+		sch.queue.Enqueue(hosts[host].job, RunOptions{})
+
+		// don't enqueue it again, while it waits in the queue
+		sch.probed[host] = time.Now().Add(retryDelay)
+	}
+}
+
+// opportunisticDue reports whether an opportunistic host should be backed
+// up as soon as it is reachable, i.e. its last success is older than its
+// desired interval, and its last failure older than retryDelay.
+func (m *metrics) opportunisticDue(now time.Time) bool {
+	if m.SucceededAt != nil && now.Sub(*m.SucceededAt) < m.job.DesiredInterval() {
+		return false
+	}
+	return m.FailedAt == nil || now.Sub(*m.FailedAt) >= retryDelay
+}
+
+// tcpProbe checks whether a TCP connection to addr can be established.
+func tcpProbe(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	return conn.Close() //nolint:wrapcheck
 }
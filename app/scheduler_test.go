@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/digineo/zackup/config"
+)
+
+func TestOpportunisticDue(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+	job := &config.JobConfig{}
+
+	for i, tt := range []struct {
+		ok, failed *time.Time
+		expected   bool
+	}{
+		{nil, nil, true},
+		{ago(23 * time.Hour), nil, false},
+		{ago(25 * time.Hour), nil, true},
+		{ago(25 * time.Hour), ago(time.Minute), false},
+		{ago(25 * time.Hour), ago(2 * time.Hour), true},
+		{nil, ago(time.Minute), false},
+	} {
+		m := &metrics{job: job, SucceededAt: tt.ok, FailedAt: tt.failed}
+		if due := m.opportunisticDue(now); due != tt.expected {
+			t.Errorf("%d: expected %v, got %v", i, tt.expected, due)
+		}
+	}
+}
+
+type fakeQueue struct {
+	mu   sync.Mutex
+	jobs []string
+}
+
+func (q *fakeQueue) Enqueue(job *config.JobConfig, _ RunOptions) {
+	q.mu.Lock()
+	q.jobs = append(q.jobs, job.Host())
+	q.mu.Unlock()
+}
+func (q *fakeQueue) EnqueueCheck(*config.JobConfig, func(*HostReport)) {}
+func (q *fakeQueue) Resize(int)                                        {}
+func (q *fakeQueue) Wait()                                             {}
+
+func TestRunOpportunistic(t *testing.T) {
+	t.Parallel()
+
+	q := &fakeQueue{}
+	sch := NewScheduler(q).(*scheduler)
+
+	var mu sync.Mutex
+	var probed []string
+	sch.probe = func(_ context.Context, addr string) error {
+		mu.Lock()
+		probed = append(probed, addr)
+		mu.Unlock()
+		if addr == "online.example.com:2222" {
+			return nil
+		}
+		return errors.New("connection refused")
+	}
+
+	tree := loadTree(t, map[string]string{
+		"config.yml":                    "---\n",
+		"globals.yml":                   "---\n",
+		"hosts/online.example.com.yml":  "ssh: {port: 2222}\nopportunistic: true\n",
+		"hosts/offline.example.com.yml": "opportunistic: true\n",
+	})
+	hosts := map[string]metrics{
+		"online.example.com":  {job: tree.Host("online.example.com")},
+		"offline.example.com": {job: tree.Host("offline.example.com")},
+	}
+
+	sch.runOpportunistic(hosts, []string{"online.example.com", "offline.example.com"})
+	if len(probed) != 2 {
+		t.Errorf("expected 2 probes, got %v", probed)
+	}
+	if len(q.jobs) != 1 || q.jobs[0] != "online.example.com" {
+		t.Errorf("expected online.example.com to be enqueued, got %v", q.jobs)
+	}
+	if !sch.probed["online.example.com"].After(time.Now().Add(retryDelay / 2)) {
+		t.Errorf("expected enqueued job to be excluded from probing")
+	}
+}
@@ -0,0 +1,201 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digineo/zackup/config"
+	"github.com/sirupsen/logrus"
+)
+
+// ScriptResult records the outcome of a single hook script.
+type ScriptResult struct {
+	Name     string        // see config.ScriptUnit
+	ExitCode int           // -1, if the script was killed or could not be started
+	Duration time.Duration // truncated to milliseconds
+	TimedOut bool
+}
+
+func (r ScriptResult) String() string {
+	if r.TimedOut {
+		return fmt.Sprintf("%s: timed out after %s", r.Name, r.Duration)
+	}
+	return fmt.Sprintf("%s: exit %d after %s", r.Name, r.ExitCode, r.Duration)
+}
+
+// heredocEOF terminates the here-document passing a script to its
+// interpreter (see unitScript).
+const heredocEOF = "ZACKUP_EOF"
+
+// unitScript returns the shell script to execute u, prefixed with the
+// given environment (see withEnv). Units with a custom interpreter are
+// passed to it as here-document:
+//
+//	exec '/usr/bin/env' 'python3' - <<'ZACKUP_EOF'
+//	...
+//	ZACKUP_EOF
+func unitScript(u config.ScriptUnit, env []string) []string {
+	if len(u.Interpreter) == 0 {
+		return withEnv(env, u.Lines)
+	}
+
+	interp := make([]string, 0, len(u.Interpreter)+1)
+	for _, arg := range scriptInterpreter(u.Interpreter) {
+		interp = append(interp, config.ShellQuote(arg))
+	}
+
+	lines := make([]string, 0, len(u.Lines)+2)
+	lines = append(lines, "exec "+strings.Join(interp, " ")+" - <<'"+heredocEOF+"'")
+	lines = append(lines, u.Lines...)
+	lines = append(lines, heredocEOF)
+	return withEnv(env, lines)
+}
+
+// shells run scripts with "-e", just like units without shebang line
+// (which are executed by "/bin/sh -esx").
+var shells = map[string]bool{
+	"sh":   true,
+	"bash": true,
+	"dash": true,
+	"ash":  true,
+	"ksh":  true,
+	"zsh":  true,
+}
+
+// scriptInterpreter returns the command line of the given interpreter.
+// Shells (also via "/usr/bin/env") get a "-e" appended, so that failing
+// commands still fail the unit.
+func scriptInterpreter(interp []string) []string {
+	name := path.Base(interp[0])
+	if name == "env" && len(interp) > 1 {
+		name = path.Base(interp[1])
+	}
+	if !shells[name] {
+		return interp
+	}
+	return append(append([]string(nil), interp...), "-e")
+}
+
+// scriptContext returns the context for a script unit, which is
+// limited to timeout, unless timeout is zero.
+func scriptContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// runScripts executes the given units one after another, each limited
+// to timeout (unless timeout is zero). It stops at the first failing
+// unit. The results are appended to res.scripts.
+func runScripts(
+	l *logrus.Entry,
+	res *runResult,
+	units []config.ScriptUnit,
+	timeout time.Duration,
+	run func(ctx context.Context, u config.ScriptUnit) error,
+) error {
+	for _, u := range units {
+		sl := l.WithField("script", u.Name)
+		sl.Info("executing script")
+
+		ctx, cancel := scriptContext(timeout)
+		started := time.Now()
+		err := run(ctx, u)
+		r := ScriptResult{
+			Name:     u.Name,
+			ExitCode: exitCode(err),
+			Duration: time.Since(started).Truncate(time.Millisecond),
+			TimedOut: err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded),
+		}
+		cancel()
+
+		res.scripts = append(res.scripts, r)
+		sl = sl.WithFields(logrus.Fields{
+			"exit": r.ExitCode,
+			"took": r.Duration,
+		})
+
+		switch {
+		case r.TimedOut:
+			sl.Error("script timed out")
+			return fmt.Errorf("%s: timed out after %s: %w", u.Name, timeout, err)
+		case err != nil:
+			sl.WithError(err).Error("script failed")
+			return fmt.Errorf("%s: %w", u.Name, err)
+		}
+		sl.Info("script finished")
+	}
+	return nil
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var xerr *exec.ExitError
+	if errors.As(err, &xerr) {
+		return xerr.ExitCode()
+	}
+	return -1
+}
+
+// encodeScripts formats results for propZackupScripts, as comma
+// separated list of "name=exit/milliseconds" (exit is "timeout" for
+// timed out scripts). An empty list is encoded as "-", long lists are
+// truncated (see joinEntries).
+func encodeScripts(results []ScriptResult) string {
+	if len(results) == 0 {
+		return "-"
+	}
+
+	entries := make([]string, len(results))
+	for i, r := range results {
+		code := strconv.Itoa(r.ExitCode)
+		if r.TimedOut {
+			code = "timeout"
+		}
+		entries[i] = fmt.Sprintf("%s=%s/%d", r.Name, code, r.Duration.Milliseconds())
+	}
+	return joinEntries(entries)
+}
+
+// decodeScripts parses the output of encodeScripts.
+func decodeScripts(value string) ([]ScriptResult, error) {
+	if value == "-" || value == "" {
+		return nil, nil
+	}
+
+	entries := strings.Split(value, ",")
+	results := make([]ScriptResult, 0, len(entries))
+	for _, entry := range entries {
+		i := strings.LastIndexByte(entry, '=')
+		j := strings.LastIndexByte(entry, '/')
+		if i < 0 || j < i {
+			return nil, fmt.Errorf("invalid entry %q", entry)
+		}
+
+		r := ScriptResult{Name: entry[:i], ExitCode: -1}
+		if code := entry[i+1 : j]; code == "timeout" {
+			r.TimedOut = true
+		} else if ival, err := strconv.Atoi(code); err == nil {
+			r.ExitCode = ival
+		} else {
+			return nil, fmt.Errorf("invalid exit code in %q: %w", entry, err)
+		}
+
+		ms, err := strconv.ParseInt(entry[j+1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration in %q: %w", entry, err)
+		}
+		r.Duration = time.Duration(ms) * time.Millisecond
+		results = append(results, r)
+	}
+	return results, nil
+}
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digineo/zackup/config"
+)
+
+func TestRunScripts(t *testing.T) {
+	t.Parallel()
+
+	out := filepath.Join(t.TempDir(), "out")
+	units := []config.ScriptUnit{
+		{Name: "a.sh", Lines: []string{"set +e", "false", "echo a >> " + out}},
+		{Name: "b.sh", Interpreter: []string{"/bin/sh", "-e"}, Lines: []string{
+			`if [ -z "$SECRET" ]; then exit 2; fi`,
+			"echo b >> " + out,
+		}},
+		{Name: "c.sh", Lines: []string{"false", "echo c >> " + out}},
+		{Name: "d.sh", Lines: []string{"echo d >> " + out}},
+	}
+
+	var res runResult
+	l := log.WithField("job", "example.com")
+	err := runScripts(l, &res, units, time.Minute, func(ctx context.Context, u config.ScriptUnit) error {
+		return executeLocal(ctx, "example.com", u.Name, unitScript(u, []string{"SECRET=it's"}), nil)
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "c.sh: local: unexpected termination") {
+		t.Errorf("expected c.sh to fail, got %v", err)
+	}
+
+	// "set +e" must not leak into c.sh, and d.sh must not be executed
+	data, _ := os.ReadFile(out)
+	if expected := "a\nb\n"; string(data) != expected {
+		t.Errorf("expected %q, got %q", expected, string(data))
+	}
+
+	var codes []int
+	for _, r := range res.scripts {
+		codes = append(codes, r.ExitCode)
+	}
+	if expected := []int{0, 0, 1}; !reflect.DeepEqual(codes, expected) {
+		t.Errorf("expected exit codes %v, got %v", expected, codes)
+	}
+}
+
+func TestRunScriptsShebangShell(t *testing.T) {
+	t.Parallel()
+
+	for _, interp := range [][]string{
+		{"/bin/sh"},
+		{"/usr/bin/env", "sh"},
+		{"/bin/sh", "-x"},
+	} {
+		out := filepath.Join(t.TempDir(), "out")
+		units := []config.ScriptUnit{{Name: "a.sh", Interpreter: interp, Lines: []string{"false", "echo a > " + out}}}
+
+		var res runResult
+		err := runScripts(log.WithField("job", "example.com"), &res, units, time.Minute,
+			func(ctx context.Context, u config.ScriptUnit) error {
+				return executeLocal(ctx, "example.com", u.Name, unitScript(u, nil), nil)
+			})
+		if err == nil {
+			t.Errorf("%v: expected failing line to fail the unit", interp)
+		}
+		if _, err := os.Stat(out); err == nil {
+			t.Errorf("%v: expected script to stop at the failing line", interp)
+		}
+	}
+}
+
+func TestRunScriptsTimeout(t *testing.T) {
+	t.Parallel()
+
+	var res runResult
+	units := []config.ScriptUnit{{Name: "slow.sh", Lines: []string{"sleep 10", "true"}}}
+	err := runScripts(log.WithField("job", "example.com"), &res, units, 100*time.Millisecond,
+		func(ctx context.Context, u config.ScriptUnit) error {
+			return executeLocal(ctx, "example.com", u.Name, u.Lines, nil)
+		})
+	if err == nil || !strings.HasPrefix(err.Error(), "slow.sh: timed out after 100ms") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+	if len(res.scripts) != 1 || !res.scripts[0].TimedOut {
+		t.Errorf("expected timed out result, got %v", res.scripts)
+	}
+}
+
+func TestEncodeScripts(t *testing.T) {
+	t.Parallel()
+
+	results := []ScriptResult{
+		{Name: "globals.yml#pre_script", ExitCode: 0, Duration: 1500 * time.Millisecond},
+		{Name: "example.com/pre.1.sh", ExitCode: 3, Duration: time.Millisecond},
+		{Name: "example.com/pre.2.sh", ExitCode: -1, Duration: time.Minute, TimedOut: true},
+	}
+
+	value := encodeScripts(results)
+	expected := "globals.yml#pre_script=0/1500," +
+		"example.com/pre.1.sh=3/1," +
+		"example.com/pre.2.sh=timeout/60000"
+	if value != expected {
+		t.Errorf("expected %q, got %q", expected, value)
+	}
+
+	decoded, err := decodeScripts(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, results) {
+		t.Errorf("expected %v, got %v", results, decoded)
+	}
+
+	if encodeScripts(nil) != "-" {
+		t.Error("expected empty list to be encoded as \"-\"")
+	}
+}
+
+func TestEncodeScriptsTruncated(t *testing.T) {
+	t.Parallel()
+
+	results := make([]ScriptResult, 200)
+	for i := range results {
+		results[i] = ScriptResult{Name: fmt.Sprintf("example.com/pre.%03d.%s.sh", i, strings.Repeat("x", 40))}
+	}
+
+	value := encodeScripts(results)
+	if len(value) > maxPropLen {
+		t.Errorf("expected at most %d bytes, got %d", maxPropLen, len(value))
+	}
+	decoded, err := decodeScripts(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(decoded); n == 0 || n >= len(results) || !reflect.DeepEqual(decoded, results[:n]) {
+		t.Errorf("expected leading results to be kept, got %d", n)
+	}
+}
@@ -153,6 +153,10 @@ func tplStatusClass(m HostMetrics) string {
 		return "table-success"
 	case StatusRunning:
 		return "table-warning"
+	case StatusPaused:
+		return "table-secondary"
+	case StatusSkipped:
+		return "table-info"
 	case StatusPrimed, StatusUnknown:
 		fallthrough
 	default:
@@ -168,6 +172,10 @@ func tplStatusIcon(m HostMetrics) string {
 		return "fas fa-check"
 	case StatusRunning:
 		return "fas fa-spinner fa-pulse"
+	case StatusPaused:
+		return "fas fa-pause"
+	case StatusSkipped:
+		return "fas fa-forward"
 	case StatusPrimed:
 		return "far fa-clock"
 	case StatusUnknown:
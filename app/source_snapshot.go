@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/digineo/zackup/config"
+	"github.com/sirupsen/logrus"
+)
+
+// withSourceSnapshot creates a temporary snapshot on the remote host,
+// and calls fn with its path. The snapshot is destroyed afterwards,
+// regardless of the outcome. Leftovers of previous runs are removed
+// before creating the snapshot. The run fails, if any of the includes
+// is not completely on the snapshotted file system (see
+// config.SourceSnapshotConfig.CheckScript).
+func withSourceSnapshot(
+	l *logrus.Entry,
+	res *runResult,
+	snap *config.SourceSnapshotConfig,
+	rsync *config.RsyncConfig,
+	timeout time.Duration,
+	run func(ctx context.Context, u config.ScriptUnit) error,
+	fn func(src string) error,
+) error {
+	l = l.WithFields(logrus.Fields{
+		"snapshot": snap.Type,
+		"source":   snap.Source,
+	})
+
+	paths, excluded := []string{"/"}, []string(nil)
+	if rsync != nil {
+		if len(rsync.Included) > 0 {
+			paths = includePrefixes(rsync.Included)
+		}
+		excluded = staticExcludes(rsync.Excluded)
+	}
+	destroyScript, err := snap.DestroyScript()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	checkScript, err := snap.CheckScript(paths, excluded)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	createScript, err := snap.CreateScript()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	create := config.ScriptUnit{
+		Name:  "source_snapshot.create",
+		Lines: append(append(append([]string{}, destroyScript...), checkScript...), createScript...),
+	}
+	destroy := config.ScriptUnit{
+		Name:  "source_snapshot.destroy",
+		Lines: destroyScript,
+	}
+
+	l.Info("creating source snapshot")
+	err = runScripts(l, res, []config.ScriptUnit{create}, timeout, run)
+	if err == nil {
+		err = fn(snap.Path())
+	}
+
+	l.Info("destroying source snapshot")
+	if destroyErr := runScripts(l, res, []config.ScriptUnit{destroy}, timeout, run); err == nil {
+		err = destroyErr
+	}
+	return err
+}
+
+// staticExcludes returns the absolute exclude patterns without wildcards.
+func staticExcludes(excludes []string) (list []string) {
+	for _, ex := range excludes {
+		if strings.HasPrefix(ex, "/") && !strings.ContainsAny(ex, "*?[") {
+			list = append(list, path.Clean(ex))
+		}
+	}
+	return list
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/digineo/zackup/config"
+)
+
+func TestWithSourceSnapshot(t *testing.T) {
+	t.Parallel()
+
+	var res runResult
+	var executed []string
+	snap := &config.SourceSnapshotConfig{Type: config.SnapshotZFS, Source: "rpool/ROOT/debian"}
+	run := func(_ context.Context, u config.ScriptUnit) error {
+		executed = append(executed, u.Name)
+		return nil
+	}
+
+	err := withSourceSnapshot(log.WithField("job", "example.com"), &res, snap, nil, time.Minute, run, func(src string) error {
+		executed = append(executed, "rsync "+src)
+		return errors.New("rsync failed")
+	})
+	if err == nil || err.Error() != "rsync failed" {
+		t.Errorf("expected rsync error, got %v", err)
+	}
+
+	expected := []string{"source_snapshot.create", "rsync /run/zackup/snapshot", "source_snapshot.destroy"}
+	if !reflect.DeepEqual(executed, expected) {
+		t.Errorf("expected %v, got %v", expected, executed)
+	}
+}
+
+func TestWithSourceSnapshotUnknownType(t *testing.T) {
+	t.Parallel()
+
+	var res runResult
+	snap := &config.SourceSnapshotConfig{Type: "lvm2", Source: "vg0/root"}
+	run := func(_ context.Context, u config.ScriptUnit) error {
+		t.Errorf("unexpected script %s", u.Name)
+		return nil
+	}
+
+	err := withSourceSnapshot(log.WithField("job", "example.com"), &res, snap, nil, time.Minute, run, func(src string) error {
+		t.Errorf("unexpected rsync from %s", src)
+		return nil
+	})
+	if err == nil {
+		t.Error("expected unknown type to fail")
+	}
+}
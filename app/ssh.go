@@ -2,6 +2,7 @@ package app
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -31,13 +32,17 @@ type sshMaster struct {
 	mu *sync.Mutex // lock for start/stop
 }
 
+// controlPathName is the name of the SSH multiplexing sockets in
+// MountBase. OpenSSH expands %C to a 40 character hash.
+const controlPathName = ".zackup_%C"
+
 func newSSHMaster(host string, cfg *config.SSHConfig) *sshMaster {
 	master := &sshMaster{
 		host: host,
 		user: cfg.User,
 		port: cfg.Port,
 
-		controlPath: filepath.Join(MountBase, ".zackup_%C"),
+		controlPath: filepath.Join(MountBase, controlPathName),
 		mountPath:   filepath.Join(MountBase, host),
 
 		mu: &sync.Mutex{},
@@ -134,12 +139,15 @@ func (c *sshMaster) close() {
 	c.tunnel = nil
 }
 
-// execute a script on the remote host:
-//	echo script | ssh -oControlPath=... host /bin/sh -esx
-func (c *sshMaster) execute(script []string) error { //nolint:funlen
-	c.wg.Add(1)
-	defer c.wg.Done()
+// remoteGrace is added to the deadline of remote scripts, so that the
+// local timeout fires first, and is reported as such.
+const remoteGrace = 5 * time.Second
 
+// shellArgs returns the ssh arguments to run "/bin/sh -esx" on the
+// remote host, reading the script from stdin. If ctx has a deadline, the
+// shell is wrapped in timeout(1) (if available), since killing the local
+// ssh client does not terminate the remote process.
+func (c *sshMaster) shellArgs(ctx context.Context) []string {
 	args := []string{
 		"-S", c.controlPath, // == -oControlPath=...
 		"-o", "ControlMaster=yes",
@@ -148,67 +156,46 @@ func (c *sshMaster) execute(script []string) error { //nolint:funlen
 	if c.connectTimeout > 0 {
 		args = append(args, "-o", fmt.Sprintf("ConnectTimeout=%d", c.connectTimeout))
 	}
-	args = append(args,
+	return append(args,
 		"-p", strconv.Itoa(int(c.port)),
 		"-x", // disable X11 forwarding
 		"-l", c.user,
 		c.host,
-		"/bin/sh", "-esx",
+		remoteShell(ctx),
 	)
-	cmd := exec.Command(SSHPath, args...)
-
-	l := log.WithFields(logrus.Fields{
-		"prefix": "ssh.execute",
-		"job":    c.host,
-	})
+}
 
-	done, wg, err := captureOutput(l, cmd)
-	if err != nil {
-		return err
+// remoteShell returns the remote command of shellArgs.
+func remoteShell(ctx context.Context) string {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return "/bin/sh -esx"
 	}
-	defer done()
 
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		l.WithError(err).Error("could not get stdin")
-		return fmt.Errorf("ssh: could not get stdin: %w", err)
-	}
-	defer stdin.Close()
+	secs := int64((time.Until(deadline) + remoteGrace + time.Second - 1) / time.Second)
+	script := fmt.Sprintf("if command -v timeout >/dev/null 2>&1; then exec timeout %d /bin/sh -esx; else exec /bin/sh -esx; fi", secs)
+	return "/bin/sh -c " + config.ShellQuote(script)
+}
 
-	if err = cmd.Start(); err != nil {
-		l.WithError(err).Error("failed to start process")
-		return fmt.Errorf("ssh: failed to start process: %w", err)
-	}
+// execute a script on the remote host:
+//	echo script | ssh -oControlPath=... host /bin/sh -esx
+func (c *sshMaster) execute(ctx context.Context, name string, script []string) error {
+	c.wg.Add(1)
+	defer c.wg.Done()
 
-	in := bufio.NewWriter(stdin)
-	for _, line := range script {
-		if _, err = in.WriteString(line + "\n"); err != nil {
-			l.WithFields(logrus.Fields{
-				logrus.ErrorKey: err,
-				"current-line":  line,
-			}).Error("failed to send script")
-			return fmt.Errorf("ssh: failed to send script: %w", err)
-		}
-		if err = in.Flush(); err != nil {
-			l.WithFields(logrus.Fields{
-				logrus.ErrorKey: err,
-				"current-line":  line,
-			}).Error("failed to execute script")
-			return fmt.Errorf("ssh: failed to execute script: %w", err)
-		}
-	}
-	stdin.Close()
-	wg.Wait()
+	cmd := exec.CommandContext(ctx, SSHPath, c.shellArgs(ctx)...)
 
-	if err = cmd.Wait(); err != nil {
-		l.WithError(err).Error("unexpected termination")
-		return fmt.Errorf("ssh: unexpected termination: %w", err)
-	}
-	return nil
+	l := log.WithFields(logrus.Fields{
+		"prefix": "ssh.execute",
+		"job":    c.host,
+		"script": name,
+	})
+
+	return pipeScript(ctx, l, "ssh", cmd, script, nil)
 }
 
-// rsync -e 'ssh -oControlPath=...' ...
-func (c *sshMaster) rsync(r *config.RsyncConfig) error {
+// rsync -e 'ssh -oControlPath=...' ... user@host:/src/ MountBase/host/
+func (c *sshMaster) rsync(r *config.RsyncConfig, src string, opts config.ArgOptions, out io.Writer) error {
 	c.wg.Add(1)
 	defer c.wg.Done()
 
@@ -221,10 +208,21 @@ func (c *sshMaster) rsync(r *config.RsyncConfig) error {
 	if c.connectTimeout > 0 {
 		sshArg += fmt.Sprintf(" -oConnectTimeout=%d", c.connectTimeout)
 	}
-	srcArg := fmt.Sprintf("%s@%s:", c.user, c.host)
+	source := config.RsyncSource{
+		Shell: sshArg,
+		Base:  fmt.Sprintf("%s@%s:", c.user, c.host),
+		Path:  src,
+	}
+	return runRsyncCmd(l, r.BuildArgVector(source, c.mountPath, opts), out)
+}
 
-	args := r.BuildArgVector(sshArg, srcArg, c.mountPath)
+// runRsyncCmd executes rsync with the given arguments. Its stdout is
+// written into out, if not nil, and logged otherwise.
+func runRsyncCmd(l *logrus.Entry, args []string, out io.Writer) error {
 	cmd := exec.Command(RSyncPath, args...)
+	if out != nil {
+		return pipeScript(context.Background(), l, "rsync", cmd, nil, out)
+	}
 
 	done, wg, err := captureOutput(l, cmd)
 	if err != nil {
@@ -246,18 +244,6 @@ func (c *sshMaster) rsync(r *config.RsyncConfig) error {
 func captureOutput(log *logrus.Entry, cmd *exec.Cmd) (func(), *sync.WaitGroup, error) {
 	wg := &sync.WaitGroup{}
 
-	capture := func(name string, r io.Reader) {
-		caplog := log.WithField("stream", name)
-		s := bufio.NewScanner(r)
-		for s.Scan() {
-			caplog.Trace(s.Text())
-		}
-		if err := s.Err(); err != nil {
-			caplog.WithError(err).Error("unexpected end of stream")
-		}
-		wg.Done()
-	}
-
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return nil, nil, err //nolint:wrapcheck
@@ -270,11 +256,25 @@ func captureOutput(log *logrus.Entry, cmd *exec.Cmd) (func(), *sync.WaitGroup, e
 	}
 
 	wg.Add(2)
-	go capture("stdout", stdout)
-	go capture("stderr", stderr)
+	go captureStream(log, "stdout", stdout, wg)
+	go captureStream(log, "stderr", stderr, wg)
 
 	return func() {
 		stderr.Close()
 		stdout.Close()
 	}, wg, nil
 }
+
+// captureStream logs each line read from r.
+func captureStream(log *logrus.Entry, name string, r io.Reader, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	caplog := log.WithField("stream", name)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		caplog.Trace(Redact(s.Text()))
+	}
+	if err := s.Err(); err != nil {
+		caplog.WithError(err).Error("unexpected end of stream")
+	}
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoteShell(t *testing.T) {
+	t.Parallel()
+
+	if cmd := remoteShell(context.Background()); cmd != "/bin/sh -esx" {
+		t.Errorf("unexpected command without deadline: %q", cmd)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	cmd := remoteShell(ctx)
+	if !strings.Contains(cmd, "exec timeout 65 /bin/sh -esx") {
+		t.Errorf("expected timeout(1) wrapper, got %q", cmd)
+	}
+
+	// ssh passes the command to the login shell of the remote user
+	sh := exec.Command("/bin/sh", "-c", cmd)
+	sh.Stdin = strings.NewReader("echo ok\n")
+	out, err := sh.Output()
+	if err != nil || string(out) != "ok\n" {
+		t.Errorf("unexpected result: %q, %v", out, err)
+	}
+}
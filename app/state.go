@@ -31,17 +31,40 @@ func InitializeState(tree config.Tree) error {
 		mu:    &sync.RWMutex{},
 	}
 
-	svc := tree.Service()
+	applyServiceConfig(tree.Service())
+	return state.load()
+}
+
+// applyServiceConfig sets the app globals from the service config.
+func applyServiceConfig(svc *config.ServiceConfig) {
 	RootDataset = svc.RootDataset
 	MountBase = svc.MountBase
+	applyBinaryPaths(svc)
+}
+
+// ReloadState reconciles the state with the (reloaded) config tree. New
+// hosts are primed, removed hosts are dropped from scheduling (but their
+// datasets are kept), and the job configs of existing hosts are updated.
+func ReloadState() error {
+	svc := state.tree.Service()
+	if svc.RootDataset != RootDataset || svc.MountBase != MountBase {
+		log.WithFields(logrus.Fields{
+			"root_dataset": RootDataset,
+			"mount_base":   MountBase,
+		}).Warn("changing root_dataset or mount_base requires a restart, keeping current values")
+	}
+	applyBinaryPaths(svc)
+
+	return state.reconcile()
+}
+
+func applyBinaryPaths(svc *config.ServiceConfig) {
 	if svc.RSyncPath != "" {
 		RSyncPath = svc.RSyncPath
 	}
 	if svc.SSHPath != "" {
 		SSHPath = svc.SSHPath
 	}
-
-	return state.load()
 }
 
 // ExportState dumps the current performance metrics.
@@ -52,6 +75,8 @@ func ExportState() []HostMetrics {
 type metrics struct {
 	job         *config.JobConfig // configured via InitializeState()
 	ScheduledAt time.Time         // configured via reschedule()
+	Disabled    bool              // "enabled: false" in job config
+	removed     bool              // removed from config tree while running
 
 	// these are properties read from ZFS
 
@@ -60,6 +85,15 @@ type metrics struct {
 	SuccessDuration           time.Duration
 	FailedAt                  *time.Time
 	FailureDuration           time.Duration
+	Failures                  uint // since last success
+	SkippedAt                 *time.Time
+	LastFullAt                *time.Time
+	IncrementalRuns           uint
+	PartialBytes              uint64
+	Paused                    bool
+	PausedUntil               *time.Time     // nil: paused indefinitely
+	Scripts                   []ScriptResult // hook scripts of the last run
+	Streams                   []StreamResult // stream files of the last successful run
 	SpaceUsedBySnapshots      uint64
 	SpaceUsedByDataset        uint64
 	SpaceUsedByChildren       uint64
@@ -77,7 +111,8 @@ type HostMetrics struct {
 	metrics
 }
 
-func (s *State) start(host string) {
+// start records the start of a run, and returns its start time.
+func (s *State) start(host string) time.Time {
 	t := time.Now().UTC()
 	s.mu.Lock()
 	if m, ok := s.hosts[host]; ok {
@@ -89,46 +124,143 @@ func (s *State) start(host string) {
 	}
 	storeStart(host, t)
 	s.mu.Unlock()
+	return t
 }
 
-func (s *State) success(host string) {
+func (s *State) success(host string, res runResult) {
 	t := time.Now().UTC()
 	s.mu.Lock()
 	if m, ok := s.hosts[host]; ok {
 		m.SucceededAt = &t
 		m.SuccessDuration = t.Sub(m.StartedAt).Truncate(time.Millisecond)
-		storeResult(host, true, t, m.SuccessDuration)
+		m.PartialBytes = res.partial
+		m.Failures = 0
+		m.Scripts = res.scripts
+		m.Streams = res.streams
+		if res.full {
+			m.LastFullAt = &t
+			m.IncrementalRuns = 0
+		} else {
+			m.IncrementalRuns++
+		}
+		extra := []string{
+			fmt.Sprintf("%s=%d", propZackupIncrementalRuns, m.IncrementalRuns),
+			fmt.Sprintf("%s=%d", propZackupPartialBytes, m.PartialBytes),
+			fmt.Sprintf("%s=%d", propZackupFailures, 0),
+			fmt.Sprintf("%s=%s", propZackupScripts, encodeScripts(res.scripts)),
+			fmt.Sprintf("%s=%s", propZackupStreams, encodeStreams(res.streams)),
+		}
+		if res.full {
+			extra = append(extra, fmt.Sprintf("%s=%d", propZackupLastFullDate, t.Unix()))
+		}
+		storeResult(host, true, t, m.SuccessDuration, extra...)
 	}
+	s.forget(host)
 	s.mu.Unlock()
 }
 
-func (s *State) failure(host string) {
+// attempt returns the number of the upcoming attempt, i.e. 1 + number
+// of failed runs since the last success.
+func (s *State) attempt(host string) uint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if m, ok := s.hosts[host]; ok {
+		return m.Failures + 1
+	}
+	return 1
+}
+
+// fullDue reports whether the next run for the given job should be a
+// full run, according to its FullEvery setting.
+func (s *State) fullDue(job *config.JobConfig) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.hosts[job.Host()]
+	if !ok {
+		return job.FullEvery.Due(nil, 0, time.Now())
+	}
+	return job.FullEvery.Due(m.LastFullAt, m.IncrementalRuns, time.Now())
+}
+
+func (s *State) failure(host string, res runResult) {
 	t := time.Now().UTC()
 	s.mu.Lock()
 	if m, ok := s.hosts[host]; ok {
 		m.FailedAt = &t
 		m.FailureDuration = t.Sub(m.StartedAt).Truncate(time.Millisecond)
-		storeResult(host, false, t, m.FailureDuration)
+		m.Failures++
+		m.Scripts = res.scripts
+		storeResult(host, false, t, m.FailureDuration,
+			fmt.Sprintf("%s=%d", propZackupFailures, m.Failures),
+			fmt.Sprintf("%s=%s", propZackupScripts, encodeScripts(res.scripts)))
 	}
+	s.forget(host)
 	s.mu.Unlock()
 }
 
-func (s *State) reschedule(host string, t time.Time) {
-	s.mu.RLock()
+// skipped records a run skipped by a pre-script. It neither counts as
+// success nor as failure.
+func (s *State) skipped(host string, res runResult) {
+	t := time.Now().UTC()
+	s.mu.Lock()
+	if m, ok := s.hosts[host]; ok {
+		m.SkippedAt = &t
+		m.Scripts = res.scripts
+		storeProps(host,
+			fmt.Sprintf("%s=%d", propZackupLastSkipDate, t.Unix()),
+			fmt.Sprintf("%s=%s", propZackupScripts, encodeScripts(res.scripts)))
+	}
+	s.forget(host)
+	s.mu.Unlock()
+}
+
+// reschedule plans the next run of host after t, and returns its time.
+func (s *State) reschedule(host string, t time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if job, ok := s.hosts[host]; ok {
 		job.ScheduledAt = s.tree.Service().NextSchedule(t)
+		return job.ScheduledAt
 	}
-	s.mu.RUnlock()
+	return time.Time{}
+}
+
+// snapshot returns a copy of the metrics of all hosts, since the host
+// list and its metrics might change during a run or config reload.
+func (s *State) snapshot() map[string]metrics {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hosts := make(map[string]metrics, len(s.hosts))
+	for host, m := range s.hosts {
+		if m != nil {
+			hosts[host] = *m
+		}
+	}
+	return hosts
 }
 
 func (s *State) load() error {
+	hosts := s.tree.Hosts()
+
+	// read the properties before taking the lock, zfs might be slow
+	props := make(map[string][]hostProp, len(hosts))
+	for _, host := range hosts {
+		p, err := readHost(host)
+		if err != nil {
+			return err
+		}
+		props[host] = p
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	now := time.Now()
 	next := s.tree.Service().NextSchedule
 
-	for _, host := range s.tree.Hosts() {
+	for _, host := range hosts {
 		if _, ok := s.hosts[host]; !ok {
 			s.hosts[host] = &metrics{
 				ScheduledAt: next(now),
@@ -136,16 +268,99 @@ func (s *State) load() error {
 		}
 		if job := s.tree.Host(host); job != nil {
 			s.hosts[host].job = job
+			s.hosts[host].Disabled = !job.IsEnabled()
+		}
+		s.hosts[host].apply(props[host])
+	}
+	return nil
+}
+
+func (s *State) reconcile() error {
+	hosts := s.tree.Hosts()
+
+	s.mu.RLock()
+	var added []string
+	for _, host := range hosts {
+		if _, ok := s.hosts[host]; !ok {
+			added = append(added, host)
 		}
-		if err := s.loadHost(host); err != nil {
+	}
+	s.mu.RUnlock()
+
+	// read the properties of new hosts before taking the lock
+	props := make(map[string][]hostProp, len(added))
+	for _, host := range added {
+		p, err := readHost(host)
+		if err != nil {
 			return err
 		}
+		props[host] = p
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	next := s.tree.Service().NextSchedule
+	known := make(map[string]bool)
+
+	for _, host := range hosts {
+		known[host] = true
+		job := s.tree.Host(host)
+
+		if m, ok := s.hosts[host]; ok {
+			m.job = job
+			m.Disabled = !job.IsEnabled()
+			m.removed = false
+			continue
+		}
+
+		log.WithField("job", host).Info("adding new host")
+		m := &metrics{
+			job:         job,
+			ScheduledAt: next(now),
+			Disabled:    !job.IsEnabled(),
+		}
+		m.apply(props[host])
+		s.hosts[host] = m
+	}
+
+	for host, m := range s.hosts {
+		if known[host] {
+			continue
+		}
+		if m.runStatus() == StatusRunning {
+			// keep it until the run has finished, so that its result
+			// is recorded
+			log.WithField("job", host).Info("removing host from schedule after current run")
+			m.removed = true
+			continue
+		}
+		log.WithField("job", host).Info("removing host from schedule")
+		delete(s.hosts, host)
 	}
 	return nil
 }
 
+// forget drops a host removed from the config tree during its run.
+//
 // unsafe, caller must lock s.mu mutex.
-func (s *State) loadHost(host string) error { //nolint:funlen
+func (s *State) forget(host string) {
+	if m, ok := s.hosts[host]; ok && m.removed {
+		log.WithField("job", host).Info("removing host from schedule")
+		delete(s.hosts, host)
+	}
+}
+
+// hostProp is a zackup property of a host's dataset.
+type hostProp struct {
+	name  string
+	value string
+}
+
+// readHost reads the zackup properties of the host's dataset. A missing
+// dataset is not an error.
+func readHost(host string) ([]hostProp, error) {
 	dataset := filepath.Join(RootDataset, host)
 	args := []string{
 		"get", "-H", "-p",
@@ -164,13 +379,13 @@ func (s *State) loadHost(host string) error { //nolint:funlen
 			"command":       append([]string{"zfs"}, args...),
 		}, o, e)
 		log.WithFields(f).Trace("failed to load state")
-		return nil
+		return nil, nil
 	}
 
 	isTab := func(r rune) bool { return r == '\t' }
-	rds := RootDataset + "/"
 	scan := bufio.NewScanner(o)
 
+	var props []hostProp
 	for scan.Scan() {
 		cols := strings.FieldsFunc(scan.Text(), isTab)
 		if len(cols) != 3 {
@@ -190,24 +405,11 @@ func (s *State) loadHost(host string) error { //nolint:funlen
 			l.Trace("ignore empty values")
 			continue
 		}
-		decoder, ok := propDecoder[cols[1]]
-		if !ok {
+		if _, ok := propDecoder[cols[1]]; !ok {
 			l.Trace("ignore non-zackup property")
 			continue
 		}
-
-		host := strings.TrimPrefix(cols[0], rds)
-		met, ok := s.hosts[host]
-		if !ok {
-			met = &metrics{}
-			s.hosts[host] = met
-		}
-
-		if err := decoder(met, cols[2]); err != nil {
-			l.WithError(err).Trace("failed to parse value, ignore")
-			continue
-		}
-		l.Trace("accepted line")
+		props = append(props, hostProp{cols[1], cols[2]})
 	}
 
 	if err := scan.Err(); err != nil {
@@ -215,9 +417,26 @@ func (s *State) loadHost(host string) error { //nolint:funlen
 			logrus.ErrorKey: err,
 			"command":       append([]string{"zfs"}, args...),
 		}, o, e)).Error("failed to load state")
-		return fmt.Errorf("failed to load state: %w", err)
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	return props, nil
+}
+
+// apply decodes the properties read by readHost into m.
+//
+// unsafe, caller must lock s.mu mutex.
+func (m *metrics) apply(props []hostProp) {
+	for _, p := range props {
+		l := log.WithFields(logrus.Fields{
+			"propname": p.name,
+			"propval":  p.value,
+		})
+		if err := propDecoder[p.name](m, p.value); err != nil {
+			l.WithError(err).Trace("failed to parse value, ignore")
+			continue
+		}
+		l.Trace("accepted line")
 	}
-	return nil
 }
 
 func (s *State) export() (ex []HostMetrics) {
@@ -229,11 +448,21 @@ func (s *State) export() (ex []HostMetrics) {
 			Host: host,
 			metrics: metrics{
 				ScheduledAt:               met.ScheduledAt,
+				Disabled:                  met.Disabled,
 				StartedAt:                 met.StartedAt,
 				SucceededAt:               met.SucceededAt,
 				SuccessDuration:           met.SuccessDuration,
 				FailedAt:                  met.FailedAt,
 				FailureDuration:           met.FailureDuration,
+				Failures:                  met.Failures,
+				SkippedAt:                 met.SkippedAt,
+				LastFullAt:                met.LastFullAt,
+				IncrementalRuns:           met.IncrementalRuns,
+				PartialBytes:              met.PartialBytes,
+				Paused:                    met.Paused,
+				PausedUntil:               met.PausedUntil,
+				Scripts:                   append([]ScriptResult(nil), met.Scripts...),
+				Streams:                   append([]StreamResult(nil), met.Streams...),
 				SpaceUsedBySnapshots:      met.SpaceUsedBySnapshots,
 				SpaceUsedByDataset:        met.SpaceUsedByDataset,
 				SpaceUsedByChildren:       met.SpaceUsedByChildren,
@@ -274,18 +503,25 @@ func storeStart(host string, t time.Time) error {
 	return nil
 }
 
-func storeResult(host string, success bool, t time.Time, dur time.Duration) error {
+// storeResult persists the outcome of a run as dataset properties. Any
+// extra "prop=value" arguments are stored alongside.
+func storeResult(host string, success bool, t time.Time, dur time.Duration, extra ...string) error {
 	propTime, propDur := propZackupLastFailureDate, propZackupLastFailureDuration
 	if success {
 		propTime, propDur = propZackupLastSuccessDate, propZackupLastSuccessDuration
 	}
 
-	args := []string{
-		"set",
+	props := []string{
 		fmt.Sprintf("%s=%d", propTime, t.Unix()),
 		fmt.Sprintf("%s=%d", propDur, int64(dur/time.Millisecond)),
-		filepath.Join(RootDataset, host),
 	}
+	return storeProps(host, append(props, extra...)...)
+}
+
+// storeProps sets the given "prop=value" pairs on the host's dataset.
+func storeProps(host string, props ...string) error {
+	args := append([]string{"set"}, props...)
+	args = append(args, filepath.Join(RootDataset, host))
 	f := logrus.Fields{
 		"command": "zfs",
 		"args":    args,
@@ -295,7 +531,7 @@ func storeResult(host string, success bool, t time.Time, dur time.Duration) erro
 	if err != nil {
 		f[logrus.ErrorKey] = err
 		log.WithFields(appendStdlogs(f, o, e)).
-			Error("failed to store result state")
+			Error("failed to store state")
 		return err
 	}
 	return nil
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReconcileKeepsRunningHosts(t *testing.T) {
+	t.Parallel()
+
+	tree := loadTree(t, map[string]string{
+		"config.yml":                 "---\n",
+		"globals.yml":                "---\n",
+		"hosts/kept.example.com.yml": "---\n",
+	})
+
+	s := &State{
+		hosts: map[string]*metrics{
+			"kept.example.com":    {},
+			"running.example.com": {StartedAt: time.Now()},
+			"idle.example.com":    {},
+		},
+		tree: tree,
+		mu:   &sync.RWMutex{},
+	}
+	if err := s.reconcile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := s.hosts["kept.example.com"]; m == nil || m.job == nil || m.removed {
+		t.Errorf("expected kept.example.com to be updated, got %+v", m)
+	}
+	if _, ok := s.hosts["idle.example.com"]; ok {
+		t.Errorf("expected idle.example.com to be removed")
+	}
+	if m := s.hosts["running.example.com"]; m == nil || !m.removed {
+		t.Fatalf("expected running.example.com to be kept until its run has finished, got %+v", m)
+	}
+
+	s.failure("running.example.com", runResult{})
+	if _, ok := s.hosts["running.example.com"]; ok {
+		t.Errorf("expected running.example.com to be removed after its run")
+	}
+}
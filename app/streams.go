@@ -0,0 +1,259 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/digineo/zackup/config"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+)
+
+// pipefail enables "set -o pipefail", if the remote shell supports it,
+// so that "cmd | zstd" fails when cmd fails.
+const pipefail = "(set -o pipefail) 2>/dev/null && set -o pipefail"
+
+// streamUnit is a rendered stream command.
+type streamUnit struct {
+	config.ScriptUnit
+	file    string // name in config.StreamsDir
+	minSize uint64
+	timeout time.Duration
+}
+
+// streamsDir returns the directory receiving the stream files of host.
+func streamsDir(host string) string {
+	return filepath.Join(MountBase, host, config.StreamsDir)
+}
+
+// StreamResult records the size of a file written by a stream or
+// database dump.
+type StreamResult struct {
+	Name string // relative to config.StreamsDir
+	Size uint64
+}
+
+func (r StreamResult) String() string {
+	return fmt.Sprintf("%s (%s)", r.Name, humanize.IBytes(r.Size))
+}
+
+// runStreams executes the stream commands one after another, and writes
+// their output into streamsDir(host). The results are appended to
+// res.scripts and res.streams.
+func runStreams(l *logrus.Entry, m transport, host string, hooks *hookScripts, res *runResult) error {
+	for _, s := range hooks.streams {
+		s.Lines = append([]string{pipefail}, s.Lines...)
+		if err := runStream(l, m, host, s, hooks.env, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runStream executes s via m, and writes its output into
+// streamsDir(host)/s.file.
+func runStream(l *logrus.Entry, m transport, host string, s streamUnit, env []string, res *runResult) error {
+	var size uint64
+	dir := streamsDir(host)
+
+	err := runScripts(l, res, []config.ScriptUnit{s.ScriptUnit}, s.timeout, func(ctx context.Context, u config.ScriptUnit) (err error) {
+		size, err = writeStream(ctx, l, dir, s, func(ctx context.Context, out io.Writer) error {
+			return m.stream(ctx, u.Name, unitScript(u, env), out)
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	res.streams = append(res.streams, StreamResult{Name: s.file, Size: size})
+	return nil
+}
+
+// writeStream calls run with a temporary file, which is renamed to
+// dir/s.file, if run succeeds and the output is at least s.minSize
+// bytes. It returns the size of the output. s.file must be a relative
+// path below dir.
+func writeStream(
+	ctx context.Context,
+	l *logrus.Entry,
+	dir string,
+	s streamUnit,
+	run func(context.Context, io.Writer) error,
+) (uint64, error) {
+	if clean := path.Clean(s.file); s.file == "" || path.IsAbs(clean) ||
+		clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return 0, fmt.Errorf("invalid stream file name %q", s.file)
+	}
+	name := filepath.Join(dir, filepath.FromSlash(s.file))
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		return 0, fmt.Errorf("creating streams directory failed: %w", err)
+	}
+
+	tmp := filepath.Join(filepath.Dir(name), "."+filepath.Base(name)+".partial")
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("creating %s failed: %w", s.file, err)
+	}
+	defer os.Remove(tmp) // no-op after rename
+
+	err = run(ctx, f)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("writing %s failed: %w", s.file, closeErr)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	fi, err := os.Stat(tmp)
+	if err != nil {
+		return 0, fmt.Errorf("writing %s failed: %w", s.file, err)
+	}
+	size := uint64(fi.Size())
+	if size < s.minSize {
+		return size, fmt.Errorf("output of %s too small: %s < %s", s.file,
+			humanize.IBytes(size), humanize.IBytes(s.minSize))
+	}
+
+	if err = os.Rename(tmp, name); err != nil {
+		return size, fmt.Errorf("writing %s failed: %w", s.file, err)
+	}
+	l.WithFields(logrus.Fields{
+		"file": s.file,
+		"size": humanize.IBytes(size),
+	}).Info("stream written")
+	return size, nil
+}
+
+// stream executes a script on the remote host, and writes its stdout
+// into out:
+//
+//	echo script | ssh -oControlPath=... host /bin/sh -esx > out
+func (c *sshMaster) stream(ctx context.Context, name string, script []string, out io.Writer) error {
+	c.wg.Add(1)
+	defer c.wg.Done()
+
+	cmd := exec.CommandContext(ctx, SSHPath, c.shellArgs(ctx)...)
+	l := log.WithFields(logrus.Fields{
+		"prefix": "ssh.stream",
+		"job":    c.host,
+		"script": name,
+	})
+	return pipeScript(ctx, l, "ssh", cmd, script, out)
+}
+
+// pipeScript starts cmd, writes script to its stdin, and its stdout into
+// out. If out is nil, stdout is logged, just like stderr. Errors are
+// prefixed with kind. If cmd runs in its own process group, the group
+// is killed when ctx is done.
+func pipeScript(ctx context.Context, l *logrus.Entry, kind string, cmd *exec.Cmd, script []string, out io.Writer) error {
+	var stdout io.ReadCloser
+	var err error
+	if out != nil {
+		cmd.Stdout = out
+	} else if stdout, err = cmd.StdoutPipe(); err != nil {
+		return fmt.Errorf("%s: could not get stdout: %w", kind, err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		if stdout != nil {
+			stdout.Close()
+		}
+		return fmt.Errorf("%s: could not get stderr: %w", kind, err)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		if stdout != nil {
+			stdout.Close()
+		}
+		stderr.Close()
+		return fmt.Errorf("%s: could not get stdin: %w", kind, err)
+	}
+	defer stdin.Close()
+
+	if err = cmd.Start(); err != nil {
+		l.WithError(err).Error("failed to start process")
+		return fmt.Errorf("%s: failed to start process: %w", kind, err)
+	}
+	if attr := cmd.SysProcAttr; attr != nil && attr.Setpgid {
+		defer killGroupOnDone(ctx, cmd)()
+	}
+
+	wg := &sync.WaitGroup{}
+	if stdout != nil {
+		wg.Add(1)
+		go captureStream(l, "stdout", stdout, wg)
+	}
+	wg.Add(1)
+	go captureStream(l, "stderr", stderr, wg)
+
+	in := bufio.NewWriter(stdin)
+	for _, line := range script {
+		if _, err = in.WriteString(line + "\n"); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = in.Flush()
+	}
+	stdin.Close()
+	wg.Wait()
+
+	if waitErr := cmd.Wait(); waitErr != nil {
+		l.WithError(waitErr).Error("unexpected termination")
+		return fmt.Errorf("%s: unexpected termination: %w", kind, waitErr)
+	}
+	if err != nil {
+		l.WithError(err).Error("failed to send script")
+		return fmt.Errorf("%s: failed to send script: %w", kind, err)
+	}
+	return nil
+}
+
+// encodeStreams formats results for propZackupStreams, as comma separated
+// list of "name=bytes". An empty list is encoded as "-", long lists are
+// truncated (see joinEntries).
+func encodeStreams(results []StreamResult) string {
+	if len(results) == 0 {
+		return "-"
+	}
+
+	entries := make([]string, len(results))
+	for i, r := range results {
+		entries[i] = fmt.Sprintf("%s=%d", r.Name, r.Size)
+	}
+	return joinEntries(entries)
+}
+
+// decodeStreams parses the output of encodeStreams.
+func decodeStreams(value string) ([]StreamResult, error) {
+	if value == "-" || value == "" {
+		return nil, nil
+	}
+
+	entries := strings.Split(value, ",")
+	results := make([]StreamResult, 0, len(entries))
+	for _, entry := range entries {
+		i := strings.LastIndexByte(entry, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid entry %q", entry)
+		}
+		size, err := strconv.ParseUint(entry[i+1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size in %q: %w", entry, err)
+		}
+		results = append(results, StreamResult{Name: entry[:i], Size: size})
+	}
+	return results, nil
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteStream(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	l := log.WithField("job", "example.com")
+	s := streamUnit{file: "dump.sql", minSize: 4}
+	write := func(content string, err error) func(context.Context, io.Writer) error {
+		return func(_ context.Context, w io.Writer) error {
+			if _, werr := io.WriteString(w, content); werr != nil {
+				return werr
+			}
+			return err
+		}
+	}
+
+	size, err := writeStream(context.Background(), l, dir, s, write("-- dump\n", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 8 {
+		t.Errorf("expected size 8, got %d", size)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "dump.sql")); string(data) != "-- dump\n" {
+		t.Errorf("unexpected content %q", data)
+	}
+
+	// failed or too small streams keep the previous file
+	_, err = writeStream(context.Background(), l, dir, s, write("--", nil))
+	if err == nil || !strings.HasPrefix(err.Error(), "output of dump.sql too small") {
+		t.Errorf("expected size error, got %v", err)
+	}
+	_, err = writeStream(context.Background(), l, dir, s, write("-- partial", errors.New("exit status 1")))
+	if err == nil || err.Error() != "exit status 1" {
+		t.Errorf("expected command error, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "dump.sql")); string(data) != "-- dump\n" {
+		t.Errorf("expected previous content, got %q", data)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected partial files to be removed, got %v", entries)
+	}
+}
+
+func TestWriteStreamInvalidName(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "streams")
+	l := log.WithField("job", "example.com")
+	for _, name := range []string{"", ".", "..", "/etc/passwd", "../dump.sql", "db/../../dump.sql"} {
+		_, err := writeStream(context.Background(), l, dir, streamUnit{file: name}, func(context.Context, io.Writer) error {
+			t.Errorf("%q: unexpected run", name)
+			return nil
+		})
+		if err == nil || !strings.HasPrefix(err.Error(), "invalid stream file name") {
+			t.Errorf("%q: expected error, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected %s not to be created", dir)
+	}
+}
+
+func TestEncodeStreams(t *testing.T) {
+	t.Parallel()
+
+	results := []StreamResult{
+		{Name: "mysql.sql.zst", Size: 1024},
+		{Name: "postgres/_globals.sql", Size: 17},
+	}
+	value := encodeStreams(results)
+	if expected := "mysql.sql.zst=1024,postgres/_globals.sql=17"; value != expected {
+		t.Errorf("expected %q, got %q", expected, value)
+	}
+
+	decoded, err := decodeStreams(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, results) {
+		t.Errorf("expected %v, got %v", results, decoded)
+	}
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/digineo/zackup/config"
+	"github.com/sirupsen/logrus"
+)
+
+// transport provides access to the data (and possibly a shell) of a
+// host. See config.TransportConfig.
+type transport interface {
+	// connect prepares the transport, e.g. by establishing an SSH
+	// connection. It must be called before any other method.
+	connect() error
+
+	// close releases resources acquired by connect.
+	close()
+
+	// execute runs a script with "/bin/sh -esx".
+	execute(ctx context.Context, name string, script []string) error
+
+	// stream runs a script with "/bin/sh -esx", and writes its stdout
+	// into out.
+	stream(ctx context.Context, name string, script []string, out io.Writer) error
+
+	// rsync copies the given source directory into MountBase/host. The
+	// output of rsync is written into out, if not nil (and logged
+	// otherwise).
+	rsync(r *config.RsyncConfig, src string, opts config.ArgOptions, out io.Writer) error
+}
+
+// errNoShell is returned by transports, which cannot execute scripts.
+var errNoShell = errors.New("transport does not support script execution")
+
+// newTransport returns the transport configured for job.
+func newTransport(job *config.JobConfig) transport {
+	host := job.Host()
+
+	switch job.TransportType() {
+	case config.TransportLocal:
+		return &localTransport{
+			host:      host,
+			root:      job.Transport.Path,
+			mountPath: filepath.Join(MountBase, host),
+		}
+	case config.TransportRsyncd:
+		return &daemonTransport{
+			host:      host,
+			source:    job.Transport.DaemonSource(host),
+			mountPath: filepath.Join(MountBase, host),
+		}
+	}
+
+	ssh := job.SSH
+	if ssh == nil {
+		ssh = &config.SSHConfig{}
+	}
+	return newSSHMaster(host, ssh)
+}
+
+// localTransport copies from a directory on the backup server (e.g. an
+// NFS mount). Scripts are executed on the backup server.
+type localTransport struct {
+	host      string
+	root      string // source directory
+	mountPath string // join(MountBase, host)
+}
+
+func (t *localTransport) connect() error {
+	fi, err := os.Stat(t.root)
+	if err != nil {
+		return fmt.Errorf("local: %w", err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("local: %s is not a directory", t.root)
+	}
+
+	// an unmounted mount point is an empty directory, and rsync would
+	// delete the whole backup
+	f, err := os.Open(t.root)
+	if err != nil {
+		return fmt.Errorf("local: %w", err)
+	}
+	defer f.Close()
+	if _, err = f.Readdirnames(1); errors.Is(err, io.EOF) {
+		return fmt.Errorf("local: %s is empty (not mounted?)", t.root)
+	} else if err != nil {
+		return fmt.Errorf("local: %w", err)
+	}
+	return nil
+}
+
+func (t *localTransport) close() {}
+
+func (t *localTransport) execute(ctx context.Context, name string, script []string) error {
+	return executeLocal(ctx, t.host, name, script, nil)
+}
+
+// stream executes a script on the backup server, and writes its stdout
+// into out:
+//
+//	echo script | /bin/sh -esx > out
+func (t *localTransport) stream(ctx context.Context, name string, script []string, out io.Writer) error {
+	cmd := exec.Command("/bin/sh", "-esx")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	l := log.WithFields(logrus.Fields{
+		"prefix": "local.stream",
+		"job":    t.host,
+		"script": name,
+	})
+	return pipeScript(ctx, l, "local", cmd, script, out)
+}
+
+// rsync ... root/src/ MountBase/host/
+func (t *localTransport) rsync(r *config.RsyncConfig, src string, opts config.ArgOptions, out io.Writer) error {
+	l := log.WithFields(logrus.Fields{
+		"prefix": "local.rsync",
+		"job":    t.host,
+	})
+
+	source := config.RsyncSource{Path: filepath.Join(t.root, src)}
+	return runRsyncCmd(l, r.BuildArgVector(source, t.mountPath, opts), out)
+}
+
+// daemonTransport copies from an rsync daemon module. It cannot execute
+// scripts.
+type daemonTransport struct {
+	host      string
+	source    config.RsyncSource
+	mountPath string // join(MountBase, host)
+}
+
+func (t *daemonTransport) connect() error { return nil }
+func (t *daemonTransport) close()         {}
+
+func (t *daemonTransport) execute(context.Context, string, []string) error {
+	return errNoShell
+}
+
+func (t *daemonTransport) stream(context.Context, string, []string, io.Writer) error {
+	return errNoShell
+}
+
+// rsync ... rsync://user@host:port/module/src/ MountBase/host/
+func (t *daemonTransport) rsync(r *config.RsyncConfig, src string, opts config.ArgOptions, out io.Writer) error {
+	l := log.WithFields(logrus.Fields{
+		"prefix": "rsyncd.rsync",
+		"job":    t.host,
+	})
+
+	source := t.source
+	source.Path = src
+	return runRsyncCmd(l, r.BuildArgVector(source, t.mountPath, opts), out)
+}
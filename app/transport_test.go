@@ -0,0 +1,73 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digineo/zackup/config"
+)
+
+func TestNewTransport(t *testing.T) {
+	t.Parallel()
+
+	job := &config.JobConfig{}
+	if _, ok := newTransport(job).(*sshMaster); !ok {
+		t.Errorf("expected ssh transport by default")
+	}
+
+	job.Transport = &config.TransportConfig{Type: config.TransportRsyncd, Module: "backup"}
+	m, ok := newTransport(job).(*daemonTransport)
+	if !ok {
+		t.Fatalf("expected rsyncd transport")
+	}
+	if err := m.execute(context.Background(), "pre", []string{"true"}); !errors.Is(err, errNoShell) {
+		t.Errorf("expected errNoShell, got %v", err)
+	}
+	if err := m.stream(context.Background(), "dump", []string{"true"}, &bytes.Buffer{}); !errors.Is(err, errNoShell) {
+		t.Errorf("expected errNoShell, got %v", err)
+	}
+}
+
+func TestLocalTransport(t *testing.T) {
+	t.Parallel()
+
+	m := &localTransport{host: "example.com", root: t.TempDir()}
+	if err := m.connect(); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("expected error for empty root, got %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(m.root, "file"), nil, 0o644); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	if err := m.connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := m.stream(context.Background(), "echo", []string{"echo hello"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "hello\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := m.stream(ctx, "sleep", []string{"sleep 10 | cat"}, &out); err == nil {
+		t.Errorf("expected error")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("stream was not interrupted, took %v", d)
+	}
+
+	m.root = "/nonexistent"
+	if err := m.connect(); err == nil {
+		t.Errorf("expected error for missing root")
+	}
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// watchDelay defines how long the Watcher waits for further changes,
+// before it triggers a reload. Editors tend to write files in multiple
+// steps, and we don't want to reload a half-written config.
+const watchDelay = 2 * time.Second
+
+// Watcher observes the config tree and triggers a reload on changes.
+type Watcher interface {
+	// Start begins watching. This method will block until you call Stop().
+	Start()
+
+	// Stop halts the watcher.
+	Stop()
+}
+
+type watcher struct {
+	root   string
+	reload func()
+	logger *logrus.Entry
+
+	fsw  *fsnotify.Watcher
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWatcher returns a new watcher for the config tree in root. It
+// calls reload, whenever files in the tree have changed. The instance
+// is not started yet, you need to call Start().
+func NewWatcher(root string, reload func()) (Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	w := &watcher{
+		root:   root,
+		reload: reload,
+		logger: log.WithField("prefix", "watcher"),
+		fsw:    fsw,
+		quit:   make(chan struct{}),
+	}
+	if err = w.addDirs(); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// addDirs adds the root directory and its subdirectories (i.e. hosts/
+// and hosts/*/) to the watch list. fsnotify does not watch recursively.
+func (w *watcher) addDirs() error {
+	return filepath.Walk(w.root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		return w.fsw.Add(name) //nolint:wrapcheck
+	})
+}
+
+func (w *watcher) Start() {
+	w.wg.Add(1)
+	defer w.wg.Done()
+
+	t := time.NewTimer(watchDelay)
+	t.Stop()
+
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.logger.WithField("event", ev.String()).Debug("config tree changed")
+			if ev.Op&fsnotify.Create != 0 {
+				// new host directories need to be watched, too
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					if err = w.fsw.Add(ev.Name); err != nil {
+						w.logger.WithError(err).Warn("failed to watch new directory")
+					}
+				}
+			}
+			t.Reset(watchDelay)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			w.logger.WithError(err).Warn("watch error")
+		case <-t.C:
+			w.logger.Info("reloading config tree")
+			w.reload()
+		case <-w.quit:
+			t.Stop()
+			return
+		}
+	}
+}
+
+func (w *watcher) Stop() {
+	close(w.quit)
+	w.wg.Wait()
+	w.fsw.Close()
+}
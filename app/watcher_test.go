@@ -0,0 +1,65 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "hosts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan struct{}, 1)
+	w, err := NewWatcher(root, func() { reloaded <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	go w.Start()
+	defer w.Stop()
+
+	// multiple changes result in a single reload
+	for _, name := range []string{"example.com.yml", "example.org.yml"} {
+		if err := os.WriteFile(filepath.Join(root, "hosts", name), []byte("---\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-reloaded:
+	case <-time.After(3 * watchDelay):
+		t.Fatal("expected reload")
+	}
+
+	select {
+	case <-reloaded:
+		t.Fatal("unexpected second reload")
+	case <-time.After(watchDelay + 500*time.Millisecond):
+	}
+}
+
+func TestWatcherStopWithoutStart(t *testing.T) {
+	t.Parallel()
+
+	w, err := NewWatcher(t.TempDir(), func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop() blocked")
+	}
+}
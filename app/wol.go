@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/digineo/zackup/config"
+	"github.com/sirupsen/logrus"
+)
+
+// wakePollInterval is the interval, in which a woken host is probed.
+const wakePollInterval = 5 * time.Second
+
+// magicPacket returns a Wake-on-LAN magic packet for mac: 6 bytes 0xff,
+// followed by 16 repetitions of mac.
+func magicPacket(mac net.HardwareAddr) []byte {
+	packet := make([]byte, 0, 6+16*len(mac))
+	for i := 0; i < 6; i++ {
+		packet = append(packet, 0xff)
+	}
+	for i := 0; i < 16; i++ {
+		packet = append(packet, mac...)
+	}
+	return packet
+}
+
+// sendMagicPacket sends a magic packet for mac to the UDP address addr.
+func sendMagicPacket(mac net.HardwareAddr, addr string) error {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return fmt.Errorf("wake_on_lan: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write(magicPacket(mac)); err != nil {
+		return fmt.Errorf("wake_on_lan: sending magic packet failed: %w", err)
+	}
+	return nil
+}
+
+// wakeHost sends a magic packet, if addr is not reachable, and waits
+// until it is (probing every poll interval). It reports whether the
+// host had to be woken up.
+func wakeHost(
+	l *logrus.Entry,
+	w *config.WakeOnLANConfig,
+	addr string,
+	probe func(ctx context.Context, addr string) error,
+	poll time.Duration,
+) (bool, error) {
+	reachable := func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), poll)
+		defer cancel()
+		return probe(ctx, addr) == nil
+	}
+	if reachable() {
+		l.Debug("host is awake")
+		return false, nil
+	}
+
+	mac, err := w.HardwareAddr()
+	if err != nil {
+		return false, fmt.Errorf("wake_on_lan: %w", err)
+	}
+	l = l.WithFields(logrus.Fields{
+		"mac":       mac.String(),
+		"broadcast": w.Address(),
+	})
+	l.Info("sending magic packet")
+	if err = sendMagicPacket(mac, w.Address()); err != nil {
+		return false, err
+	}
+
+	deadline := time.Now().Add(w.MaxWait())
+	for time.Now().Before(deadline) {
+		time.Sleep(poll)
+		if reachable() {
+			l.Info("host woke up")
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("wake_on_lan: %s not reachable after %s", addr, w.MaxWait())
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/digineo/zackup/config"
+)
+
+func TestSendMagicPacket(t *testing.T) {
+	t.Parallel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	mac, _ := net.ParseMAC("00:11:22:aa:bb:cc")
+	if err = sendMagicPacket(mac, conn.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 256)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := buf[:n]
+	if len(packet) != 102 {
+		t.Fatalf("expected 102 bytes, got %d", len(packet))
+	}
+	if !bytes.Equal(packet[:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("unexpected header % x", packet[:6])
+	}
+	for i := 6; i < len(packet); i += 6 {
+		if !bytes.Equal(packet[i:i+6], mac) {
+			t.Errorf("unexpected MAC at offset %d: % x", i, packet[i:i+6])
+		}
+	}
+}
+
+func TestWakeHost(t *testing.T) {
+	t.Parallel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	l := log.WithField("job", "example.com")
+
+	// host comes up after the magic packet
+	awake := make(chan struct{})
+	go func() {
+		buf := make([]byte, 256)
+		if _, _, err := conn.ReadFrom(buf); err == nil {
+			close(awake)
+		}
+	}()
+	probe := func(context.Context, string) error {
+		select {
+		case <-awake:
+			return nil
+		default:
+			return errors.New("connection refused")
+		}
+	}
+
+	w := &config.WakeOnLANConfig{MAC: "00:11:22:aa:bb:cc", Broadcast: conn.LocalAddr().String()}
+	woken, err := wakeHost(l, w, "example.com:22", probe, 10*time.Millisecond)
+	if err != nil || !woken {
+		t.Errorf("expected host to be woken, got %v, %v", woken, err)
+	}
+
+	// already awake
+	woken, err = wakeHost(l, w, "example.com:22", probe, 10*time.Millisecond)
+	if err != nil || woken {
+		t.Errorf("expected host to be awake, got %v, %v", woken, err)
+	}
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/digineo/zackup/config"
+	"github.com/sirupsen/logrus"
+)
+
+// runZFSSend replicates the datasets of the zfs-send transport into
+// RootDataset/host/dataset. Each dataset gets a snapshot with the given
+// name on the remote host, which is sent incrementally from the last
+// snapshot or bookmark both sides have in common. res.full is set, if
+// any dataset needed a full stream.
+func runZFSSend(l *logrus.Entry, m transport, job *config.JobConfig, name string, timeout time.Duration, res *runResult) error {
+	res.full = false
+	root := newDataset(job.Host()).Name
+
+	for _, src := range job.Transport.Datasets {
+		dl := l.WithField("dataset", src)
+		if err := pullDataset(dl, m, src, path.Join(root, src), name, timeout, res); err != nil {
+			return fmt.Errorf("%s: %w", src, err)
+		}
+	}
+	return nil
+}
+
+// pullDataset replicates the remote dataset src into the local dataset dst.
+func pullDataset(l *logrus.Entry, m transport, src, dst, name string, timeout time.Duration, res *runResult) error {
+	remote := func(ctx context.Context, u config.ScriptUnit) error {
+		return m.execute(ctx, u.Name, unitScript(u, nil))
+	}
+	unit := func(step string, script []string) []config.ScriptUnit {
+		return []config.ScriptUnit{{Name: "zfs_send/" + src + "/" + step, Lines: script}}
+	}
+
+	local, exists, err := localSnapshots(dst)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	err = runScripts(l, res, unit("list", config.ZFSListScript(src)), timeout, func(ctx context.Context, u config.ScriptUnit) error {
+		out.Reset()
+		return m.stream(ctx, u.Name, unitScript(u, nil), &out)
+	})
+	if err != nil {
+		return err
+	}
+	entries := parseZFSList(out.String(), src)
+
+	base := commonBase(entries, local)
+	if base == "" && len(local) > 0 {
+		return fmt.Errorf("no common snapshot or bookmark with %s, destroy it to start over", dst)
+	}
+
+	if err = runScripts(l, res, unit("snapshot", config.ZFSSnapshotScript(src, name)), timeout, remote); err != nil {
+		return err
+	}
+
+	if base != "" {
+		// the local base snapshot might have been pruned in the meantime
+		if local, exists, err = localSnapshots(dst); err != nil {
+			return err
+		}
+		if current := commonBase(entries, local); current != base {
+			l.WithField("base", base).Warn("local base snapshot is gone")
+			if current == "" && len(local) > 0 {
+				return fmt.Errorf("no common snapshot or bookmark with %s, destroy it to start over", dst)
+			}
+			base = current
+		}
+	}
+
+	if base == "" {
+		l.Info("receiving full stream")
+		res.full = true
+	} else {
+		l.WithField("base", base).Info("receiving incremental stream")
+	}
+	// like rsync, the transfer itself is not limited
+	err = runScripts(l, res, unit("send", config.ZFSSendScript(src, base, name)), 0, func(ctx context.Context, u config.ScriptUnit) error {
+		return receive(ctx, l, m, u, dst, base, exists)
+	})
+	if err != nil {
+		return err
+	}
+
+	// every zackup snapshot is obsolete, as well as bookmarks, whose
+	// snapshot has been removed locally
+	var obsolete []string
+	for _, e := range entries {
+		if !e.bookmark || !local[e.name] {
+			obsolete = append(obsolete, e.full)
+		}
+	}
+	return runScripts(l, res, unit("release", config.ZFSReleaseScript(src, name, obsolete)), timeout, remote)
+}
+
+// zfsEntry is a snapshot or bookmark of a remote dataset.
+type zfsEntry struct {
+	full     string // "ds@name" or "ds#name"
+	name     string
+	bookmark bool
+}
+
+// parseZFSList parses the output of config.ZFSListScript, and returns
+// the entries created by zackup, in order.
+func parseZFSList(out, ds string) (entries []zfsEntry) {
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) <= len(ds) || !strings.HasPrefix(line, ds) {
+			continue
+		}
+		sep, name := line[len(ds)], line[len(ds)+1:]
+		if (sep != '@' && sep != '#') || !strings.HasPrefix(name, config.ZFSSendPrefix) {
+			continue
+		}
+		entries = append(entries, zfsEntry{full: line, name: name, bookmark: sep == '#'})
+	}
+	return entries
+}
+
+// commonBase returns the full name of the most recent entry, which has
+// a local snapshot of the same name. Snapshots are preferred over
+// bookmarks of the same name.
+func commonBase(entries []zfsEntry, local map[string]bool) string {
+	var base zfsEntry
+	for _, e := range entries {
+		if !local[e.name] {
+			continue
+		}
+		if base.name != e.name || base.bookmark {
+			base = e
+		}
+	}
+	return base.full
+}
+
+// localSnapshots returns the names of the zackup snapshots of dst, and
+// whether dst exists.
+func localSnapshots(dst string) (map[string]bool, bool, error) {
+	o, e, err := execZFS("list", "-H", "-o", "name", "-t", "snapshot", "-d", "1", dst)
+	if err != nil {
+		if strings.Contains(e.String(), "does not exist") {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to list snapshots of %q: %w: %s", dst, err, strings.TrimSpace(e.String()))
+	}
+
+	snaps := make(map[string]bool)
+	for _, line := range strings.Split(o.String(), "\n") {
+		if i := strings.IndexByte(line, '@'); i >= 0 && strings.HasPrefix(line[i+1:], config.ZFSSendPrefix) {
+			snaps[line[i+1:]] = true
+		}
+	}
+	return snaps, true, nil
+}
+
+// receive pipes the output of send (see config.ZFSSendScript) into
+// zfs receive:
+//
+//	ssh ... zfs send -p [-i base] src@name | zfs receive [-F] -x mountpoint -o readonly=on dst
+//
+// The received dataset inherits its mountpoint from RootDataset/host,
+// and is read-only, since local changes would break the next incremental
+// stream (or be rolled back by -F). A
+// full stream overwrites an existing (snapshot-less) dst. Both sides are
+// killed, when ctx is done.
+func receive(ctx context.Context, l *logrus.Entry, m transport, send config.ScriptUnit, dst, base string, exists bool) error {
+	if parent := path.Dir(dst); !exists {
+		if err := zfs("create", "-p", parent); err != nil {
+			return fmt.Errorf("failed to zfs create %q: %w", parent, err)
+		}
+	}
+
+	args := []string{"receive", "-x", "mountpoint", "-o", "readonly=on"}
+	if base == "" && exists {
+		args = append(args, "-F")
+	}
+	args = append(args, dst)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %w", err)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "zfs", args...)
+	cmd.Stdin = r
+	cmd.Stderr = &stderr
+	if err = cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return fmt.Errorf("failed to start zfs receive: %w", err)
+	}
+	r.Close()
+
+	sendErr := m.stream(ctx, send.Name, unitScript(send, nil), w)
+	w.Close()
+	recvErr := cmd.Wait()
+
+	if recvErr != nil {
+		l.WithFields(appendStdlogs(logrus.Fields{
+			logrus.ErrorKey: recvErr,
+			"command":       append([]string{"zfs"}, args...),
+		}, nil, &stderr)).Error("executing zfs failed")
+		return fmt.Errorf("zfs receive failed: %w", recvErr)
+	}
+	if sendErr != nil {
+		return fmt.Errorf("zfs send failed: %w", sendErr)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseZFSList(t *testing.T) {
+	t.Parallel()
+
+	out := "tank/data@manual\n" +
+		"tank/data#zackup_1\n" +
+		"tank/data/child@zackup_1\n" +
+		"tank/data@zackup_2\n" +
+		"tank/data#zackup_2\n" +
+		"tank/data@zackup_3\n"
+
+	entries := parseZFSList(out, "tank/data")
+	expected := []zfsEntry{
+		{full: "tank/data#zackup_1", name: "zackup_1", bookmark: true},
+		{full: "tank/data@zackup_2", name: "zackup_2"},
+		{full: "tank/data#zackup_2", name: "zackup_2", bookmark: true},
+		{full: "tank/data@zackup_3", name: "zackup_3"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %v, got %v", expected, entries)
+	}
+
+	tests := []struct {
+		local    map[string]bool
+		expected string
+	}{
+		{nil, ""},
+		{map[string]bool{"zackup_1": true}, "tank/data#zackup_1"},
+		{map[string]bool{"zackup_1": true, "zackup_2": true}, "tank/data@zackup_2"},
+		{map[string]bool{"zackup_2": true, "zackup_3": true}, "tank/data@zackup_3"},
+		{map[string]bool{"zackup_0": true}, ""},
+	}
+	for _, tt := range tests {
+		if base := commonBase(entries, tt.local); base != tt.expected {
+			t.Errorf("commonBase(%v): expected %q, got %q", tt.local, tt.expected, base)
+		}
+	}
+}
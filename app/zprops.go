@@ -24,8 +24,38 @@ const (
 	propZackupLastSuccessDuration = propZackupNS + "s_duration" // duration
 	propZackupLastFailureDate     = propZackupNS + "f_date"     // unix timestamp
 	propZackupLastFailureDuration = propZackupNS + "f_duration" // duration
+	propZackupLastSkipDate        = propZackupNS + "k_date"     // unix timestamp
+	propZackupLastFullDate        = propZackupNS + "full_date"  // unix timestamp
+	propZackupIncrementalRuns     = propZackupNS + "incr_runs"  // number of incremental runs since last full run
+	propZackupPartialBytes        = propZackupNS + "partial"    // bytes of partial files found at the start of the last successful run
+	propZackupRunType             = propZackupNS + "type"       // "full" or "incremental", set on snapshots
+	propZackupPausedUntil         = propZackupNS + "paused"     // unix timestamp, 0 means indefinitely
+	propZackupFailures            = propZackupNS + "failures"   // number of failed runs since last success
+	propZackupScripts             = propZackupNS + "scripts"    // hook script results of last run, see encodeScripts()
+	propZackupStreams             = propZackupNS + "streams"    // stream file sizes of last successful run, see encodeStreams()
 )
 
+// maxPropLen is the maximum length of a user property value (ZFS allows
+// 8 KiB, including the terminating NUL byte).
+const maxPropLen = 8*1024 - 1
+
+// joinEntries joins the entries of a list property with ",". Trailing
+// entries exceeding maxPropLen are dropped. An empty list is encoded
+// as "-".
+func joinEntries(entries []string) string {
+	n := -1
+	for i, e := range entries {
+		if n += len(e) + 1; n > maxPropLen {
+			entries = entries[:i]
+			break
+		}
+	}
+	if len(entries) == 0 {
+		return "-"
+	}
+	return strings.Join(entries, ",")
+}
+
 var zackupProps = strings.Join([]string{
 	// system properties
 	propUsedBySnapshots,
@@ -38,6 +68,13 @@ var zackupProps = strings.Join([]string{
 	propZackupLastStart,
 	propZackupLastSuccessDate, propZackupLastSuccessDuration,
 	propZackupLastFailureDate, propZackupLastFailureDuration,
+	propZackupLastSkipDate,
+	propZackupLastFullDate, propZackupIncrementalRuns,
+	propZackupPartialBytes,
+	propZackupPausedUntil,
+	propZackupFailures,
+	propZackupScripts,
+	propZackupStreams,
 }, ",")
 
 type decodeError struct {
@@ -135,4 +172,74 @@ var propDecoder = map[string]func(*metrics, string) error{
 		}
 		return &decodeError{propZackupLastFailureDuration, err}
 	},
+	propZackupLastSkipDate: func(m *metrics, value string) error {
+		ival, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			t := time.Unix(ival, 0)
+			m.SkippedAt = &t
+		}
+		return &decodeError{propZackupLastSkipDate, err}
+	},
+
+	propZackupLastFullDate: func(m *metrics, value string) error {
+		ival, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			t := time.Unix(ival, 0)
+			m.LastFullAt = &t
+		}
+		return &decodeError{propZackupLastFullDate, err}
+	},
+
+	propZackupIncrementalRuns: func(m *metrics, value string) error {
+		uval, err := strconv.ParseUint(value, 10, 32)
+		if err == nil {
+			m.IncrementalRuns = uint(uval)
+		}
+		return &decodeError{propZackupIncrementalRuns, err}
+	},
+
+	propZackupPartialBytes: func(m *metrics, value string) error {
+		uval, err := strconv.ParseUint(value, 10, 64)
+		if err == nil {
+			m.PartialBytes = uval
+		}
+		return &decodeError{propZackupPartialBytes, err}
+	},
+
+	propZackupPausedUntil: func(m *metrics, value string) error {
+		ival, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			m.Paused = true
+			m.PausedUntil = nil
+			if ival > 0 {
+				t := time.Unix(ival, 0)
+				m.PausedUntil = &t
+			}
+		}
+		return &decodeError{propZackupPausedUntil, err}
+	},
+
+	propZackupFailures: func(m *metrics, value string) error {
+		uval, err := strconv.ParseUint(value, 10, 32)
+		if err == nil {
+			m.Failures = uint(uval)
+		}
+		return &decodeError{propZackupFailures, err}
+	},
+
+	propZackupScripts: func(m *metrics, value string) error {
+		results, err := decodeScripts(value)
+		if err == nil {
+			m.Scripts = results
+		}
+		return &decodeError{propZackupScripts, err}
+	},
+
+	propZackupStreams: func(m *metrics, value string) error {
+		results, err := decodeStreams(value)
+		if err == nil {
+			m.Streams = results
+		}
+		return &decodeError{propZackupStreams, err}
+	},
 }
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"github.com/digineo/zackup/app"
+	"github.com/spf13/cobra"
+)
+
+var agentOpts = app.AgentOptions{}
+
+// agentCmd represents the agent command.
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Pushes a backup of this host to a zackup server",
+	Long: `Pushes a backup of this host to a zackup server, for hosts which
+cannot be reached by the server (e.g. laptops or hosts behind NAT).
+
+The agent connects via SSH to the server, where the key must be bound
+to the forced command "zackup receive HOST". It fetches the backup plan
+from the merged host config, runs the pre-, post- and finally-scripts
+locally, and pushes "/" with rsync into the host's dataset. The server
+runs the local scripts, creates the snapshot and updates the state.
+
+The agent does not need a config tree.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if err := app.RunAgent(agentOpts); err != nil {
+			log.WithError(err).Fatal("backup failed")
+		}
+	},
+}
+
+func init() { //nolint:gochecknoinits
+	rootCmd.AddCommand(agentCmd)
+	agentCmd.PersistentFlags().StringVarP(&agentOpts.Server, "server", "s", "", "zackup server as `[user@]host`")
+	agentCmd.PersistentFlags().Uint16VarP(&agentOpts.Port, "port", "p", 0, "SSH `port` of the server")
+	agentCmd.PersistentFlags().StringVarP(&agentOpts.IdentityFile, "identity", "i", "", "SSH private key `file`")
+	_ = agentCmd.MarkPersistentFlagRequired("server")
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/digineo/zackup/app"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkParallel = 0
+	checkJSON     = false
+)
+
+// checkCmd represents the check command.
+var checkCmd = &cobra.Command{
+	Use:   "check [host|@group [...]]",
+	Short: "Checks connectivity and prerequisites of hosts, without backing them up",
+	Long: `Checks connectivity and prerequisites of hosts, without backing them
+up. For each host, the connection is established (verifying the SSH
+host key), the version of the remote rsync is checked, the include
+paths (or the datasets for zfs send) must exist and be readable, and
+the remote hook scripts are checked with "sh -n" on the host.
+
+The hosts are checked in parallel (see --parallel). Exits with a
+non-zero status if any check failed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		requireValidConfig()
+
+		if checkParallel > 0 {
+			queue.Resize(checkParallel)
+		}
+		if len(args) == 0 {
+			args = tree.Hosts()
+		} else {
+			args = expandHostArgs(args)
+		}
+
+		var mu sync.Mutex
+		reports := make(map[string]*app.HostReport, len(args))
+		for _, host := range args {
+			job := tree.Host(host)
+			if job == nil {
+				log.WithField("job", host).Warn("unknown host, ignoring")
+				continue
+			}
+			queue.EnqueueCheck(job, func(r *app.HostReport) {
+				mu.Lock()
+				reports[r.Host] = r
+				mu.Unlock()
+			})
+		}
+		queue.Wait()
+
+		// keep the order of the arguments
+		list := make([]*app.HostReport, 0, len(reports))
+		failed := false
+		for _, host := range args {
+			if r, ok := reports[host]; ok {
+				list = append(list, r)
+				failed = failed || r.Status == app.CheckFail
+			}
+		}
+
+		if checkJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(list); err != nil {
+				log.WithError(err).Fatal("failed to encode reports")
+			}
+		} else {
+			printReports(list)
+		}
+
+		if failed {
+			gl.Flush()
+			os.Exit(1)
+		}
+	},
+}
+
+func printReports(list []*app.HostReport) {
+	longest := 0
+	for _, r := range list {
+		for _, c := range r.Checks {
+			if l := len(c.Name); l > longest {
+				longest = l
+			}
+		}
+	}
+	ws := strings.Repeat(" ", longest)
+	colored := isTerminal()
+
+	for _, r := range list {
+		fmt.Printf("%s  %s (%s)\n", r.Host, colorizeCheck(r.Status, colored), r.Transport)
+		for _, c := range r.Checks {
+			fmt.Printf("  %s  %-[2]*s  %s\n", colorizeCheck(c.Status, colored), longest, c.Name, c.Message)
+			if c.Hint != "" {
+				fmt.Printf("        %s  hint: %s\n", ws, c.Hint)
+			}
+		}
+	}
+}
+
+func init() { //nolint:gochecknoinits
+	rootCmd.AddCommand(checkCmd)
+	checkCmd.Flags().IntVarP(&checkParallel, "parallel", "P", 0,
+		"Check at most `N` hosts parallel (overrides service config value, if > 0)")
+	checkCmd.Flags().BoolVarP(&checkJSON, "json", "", checkJSON,
+		"Print the reports as JSON")
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/digineo/zackup/config"
+	"github.com/spf13/cobra"
+)
+
+// configCmd groups config related subcommands.
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspects the config tree",
+}
+
+// configCheckCmd represents the config check command.
+var configCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Validates the config tree and lists all problems found",
+	Long: `Validates the config tree and lists all problems found.
+
+Config files are decoded strictly (i.e. unknown keys are reported), each
+field is validated and the merged hook scripts of every host are checked
+for syntax errors with "sh -n". Config files which cannot be read or
+decoded are reported as well, and skipped.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		tr := config.CheckTree(treeRoot)
+		findings := tr.Findings()
+		for _, host := range tr.Hosts() {
+			findings = append(findings, checkScripts(tr.Host(host))...)
+		}
+
+		for _, f := range findings {
+			fmt.Println(f.String())
+		}
+
+		if len(findings) > 0 {
+			fmt.Fprintf(os.Stderr, "%d problem(s) found\n", len(findings))
+			gl.Flush()
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "no problems found")
+	},
+}
+
+// configShowCmd represents the config show command.
+var configShowCmd = &cobra.Command{
+	Use:   "show host",
+	Short: "Prints the effective config for a host",
+	Long: `Prints the effective config for a host, i.e. the host config merged
+with the global config, as YAML. Each value is annotated with the file
+it came from.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		out, err := tree.Describe(args[0])
+		if err != nil {
+			log.WithError(err).Fatal("failed to render config")
+		}
+		os.Stdout.Write(out)
+	},
+}
+
+func checkScripts(job *config.JobConfig) (findings []config.Finding) {
+	if job == nil {
+		return nil
+	}
+	for _, hook := range job.Hooks() {
+		if err := hook.Script.SyntaxCheck(); err != nil {
+			findings = append(findings, config.Finding{
+				File:    job.File(),
+				Host:    job.Host(),
+				Message: fmt.Sprintf("%s: %v", hook.Key, err),
+			})
+		}
+	}
+	return findings
+}
+
+func init() { //nolint:gochecknoinits
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configCheckCmd)
+	configCmd.AddCommand(configShowCmd)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/digineo/zackup/app"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// doctorCmd represents the doctor command.
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Checks the prerequisites of the backup server",
+	Long: `Checks the prerequisites of the backup server implied by the service
+config: the zfs, rsync, ssh and sh binaries, the rsync version, the root
+dataset and its mountpoint, the mount base and the length of the SSH
+ControlPath sockets.
+
+Each check is printed with its outcome (pass, warn or fail) and, if it
+did not pass, a hint how to fix it. Exits with a non-zero status if any
+check failed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		svc := tree.Service()
+		if svc == nil {
+			log.Fatal("no service config found")
+		}
+
+		results := app.Doctor(svc)
+		longest := 0
+		for _, r := range results {
+			if l := len(r.Name); l > longest {
+				longest = l
+			}
+		}
+
+		colored := isTerminal()
+		failed := 0
+		for _, r := range results {
+			fmt.Printf("%s  %-[2]*s  %s\n", colorizeCheck(r.Status, colored), longest, r.Name, r.Message)
+			if r.Hint != "" {
+				fmt.Printf("%s  %s  hint: %s\n", strings.Repeat(" ", 4), strings.Repeat(" ", longest), r.Hint)
+			}
+			if r.Status == app.CheckFail {
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			fmt.Fprintf(os.Stderr, "%d check(s) failed\n", failed)
+			gl.Flush()
+			os.Exit(1)
+		}
+	},
+}
+
+// colorizeCheck returns the upper-cased status, which is colored only
+// if requested.
+func colorizeCheck(s app.CheckStatus, colored bool) string {
+	label := strings.ToUpper(s.String())
+	if !colored {
+		return label
+	}
+
+	var color string
+
+	switch s {
+	case app.CheckPass:
+		color = "1;32" // green
+	case app.CheckWarn:
+		color = "0;33" // yellow
+	case app.CheckFail:
+		color = "1;31" // red
+	}
+	if color != "" {
+		return fmt.Sprintf("\033[%sm%s\033[0m", color, label)
+	}
+	return label
+}
+
+// isTerminal reports whether stdout is a terminal, i.e. whether colored
+// output can be used.
+func isTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+func init() { //nolint:gochecknoinits
+	rootCmd.AddCommand(doctorCmd)
+}
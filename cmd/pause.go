@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/digineo/zackup/app"
+	"github.com/digineo/zackup/config"
+	"github.com/spf13/cobra"
+)
+
+var pauseUntil = ""
+
+// pauseCmd represents the pause command.
+var pauseCmd = &cobra.Command{
+	Use:   "pause host|@group [...]",
+	Short: "Suspends scheduled backups for hosts",
+	Long: `Suspends scheduled backups for the given hosts, either indefinitely
+or until the time given with --until. The pause is stored as property of
+the host's dataset, and is picked up by a running "zackup serve".
+
+Explicitly running a paused host with "zackup run host" still works.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		until, err := parseUntil(pauseUntil, time.Now())
+		if err != nil {
+			log.WithError(err).Fatal("invalid --until value")
+		}
+
+		failed := false
+		for _, host := range expandHostArgs(args) {
+			l := log.WithField("job", host)
+			if err := app.Pause(host, until); err != nil {
+				l.WithError(err).Error("failed to pause host")
+				failed = true
+				continue
+			}
+			if until.IsZero() {
+				l.Info("host paused")
+			} else {
+				l.WithField("until", until.Format(time.RFC3339)).Info("host paused")
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// resumeCmd represents the resume command.
+var resumeCmd = &cobra.Command{
+	Use:   "resume host|@group [...]",
+	Short: "Resumes scheduled backups for paused hosts",
+	Long: `Resumes scheduled backups for hosts paused with "zackup pause".
+Hosts disabled in their config ("enabled: false") are not affected.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		failed := false
+		for _, host := range expandHostArgs(args) {
+			l := log.WithField("job", host)
+			if err := app.Resume(host); err != nil {
+				l.WithError(err).Error("failed to resume host")
+				failed = true
+				continue
+			}
+			l.Info("host resumed")
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// parseUntil parses the --until flag. It accepts a duration relative to
+// now (e.g. "36h" or "7d"), a date ("2006-01-02", meaning midnight), a
+// local time ("2006-01-02 15:04") or an RFC 3339 timestamp. An empty
+// string results in a zero time.
+func parseUntil(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if dur, err := config.ParseDuration(s); err == nil {
+		if dur <= 0 {
+			return time.Time{}, fmt.Errorf("duration %q must be positive", s)
+		}
+		return now.Add(dur), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as duration or time", s)
+}
+
+func init() { //nolint:gochecknoinits
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+	pauseCmd.PersistentFlags().StringVarP(&pauseUntil, "until", "u", pauseUntil,
+		"pause until `TIME` (a duration like \"36h\" or \"7d\", a date, or \"YYYY-MM-DD HH:MM\")")
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/digineo/zackup/app"
+	"github.com/spf13/cobra"
+)
+
+// receiveCmd represents the receive command.
+var receiveCmd = &cobra.Command{
+	Use:   "receive host",
+	Short: "Handles connections of a zackup agent (SSH forced command)",
+	Long: `Handles connections of "zackup agent" for the given host, which
+must use "transport: push". It is meant to be used as forced command in
+the authorized_keys file of the backup server, e.g.:
+
+  restrict,command="zackup receive laptop.example.com" ssh-ed25519 AAAA...
+
+The requested command is read from SSH_ORIGINAL_COMMAND.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		host := args[0]
+		job := tree.Host(host)
+		if job == nil {
+			log.WithField("job", host).Fatal("unknown host")
+		}
+
+		command := os.Getenv("SSH_ORIGINAL_COMMAND")
+		if err := app.ServePush(job, command, os.Stdin, os.Stdout); err != nil {
+			log.WithError(err).WithField("job", host).Fatal("push failed")
+		}
+	},
+}
+
+func init() { //nolint:gochecknoinits
+	rootCmd.AddCommand(receiveCmd)
+}
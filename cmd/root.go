@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"os"
+	"strings"
 
 	"github.com/digineo/goldflags"
 	"github.com/digineo/zackup/app"
@@ -23,6 +24,10 @@ var (
 	glEndpoint string
 )
 
+func init() { //nolint:gochecknoinits
+	gl.SetRedactor(app.Redact)
+}
+
 // rootCmd represents the base command when called without any subcommands.
 var rootCmd = &cobra.Command{
 	Use:     "zackup",
@@ -61,21 +66,44 @@ func initConfig() {
 		log.Debug("increase log level (debug)")
 	}
 
+	// the agent runs on the client, without config tree and ZFS
+	if agentCmd.CalledAs() != "" {
+		return
+	}
+
 	if treeRoot == "" {
 		if envRoot := os.Getenv("ZACKUP_ROOT"); envRoot != "" {
 			treeRoot = envRoot
 		}
 	}
 
+	// "config check" loads the tree itself, to report every problem
+	if configCheckCmd.CalledAs() != "" {
+		return
+	}
+
 	l := log.WithField("root", treeRoot)
 	if err := tree.SetRoot(treeRoot); err != nil {
 		log.WithError(err).Fatalf("failed to read config tree")
 	}
 	l.Info("config tree read")
 
+	logFindings()
+
 	hosts := tree.Hosts()
-	injectHostArgs(hosts, runCmd)
-	injectHostArgs(hosts, statusCmd)
+	selectors := hosts
+	for _, group := range tree.Groups() {
+		selectors = append(selectors, "@"+group)
+	}
+	injectHostArgs(selectors, runCmd)
+	injectHostArgs(selectors, checkCmd)
+	injectHostArgs(selectors, statusCmd)
+	injectHostArgs(selectors, pauseCmd)
+	injectHostArgs(selectors, resumeCmd)
+	pauseCmd.Args = cobra.MatchAll(cobra.MinimumNArgs(1), cobra.OnlyValidArgs)
+	resumeCmd.Args = cobra.MatchAll(cobra.MinimumNArgs(1), cobra.OnlyValidArgs)
+	injectHostArgs(hosts, configShowCmd)
+	configShowCmd.Args = cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs)
 
 	if svc := tree.Service(); svc != nil {
 		if verbosity == 0 {
@@ -84,12 +112,62 @@ func initConfig() {
 
 		queue.Resize(int(svc.Parallel))
 
+		// the doctor checks the prerequisites of the state itself
+		if doctorCmd.CalledAs() != "" {
+			return
+		}
 		if err := app.InitializeState(tree); err != nil {
 			l.WithError(err).Fatalf("state initialization failed")
 		}
 	}
 }
 
+func logFindings() {
+	for _, f := range tree.Findings() {
+		fl := log.WithField("file", f.File)
+		if f.Host != "" {
+			fl = fl.WithField("job", f.Host)
+		}
+		fl.Warn(f.Message)
+	}
+}
+
+// requireValidConfig aborts, if the service runs in strict mode and
+// the config tree contains problems.
+func requireValidConfig() {
+	svc := tree.Service()
+	if svc == nil || !svc.Strict {
+		return
+	}
+	if n := len(tree.Findings()); n > 0 {
+		log.WithField("findings", n).Fatal("strict mode: refusing to start with config problems, see `zackup config check`")
+	}
+}
+
+// expandHostArgs replaces group selectors ("@group") in args with the
+// group's member hosts. Duplicates are removed.
+func expandHostArgs(args []string) []string {
+	seen := make(map[string]bool)
+	hosts := make([]string, 0, len(args))
+	add := func(host string) {
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "@") {
+			for _, host := range tree.GroupHosts(arg[1:]) {
+				add(host)
+			}
+			continue
+		}
+		add(arg)
+	}
+	return hosts
+}
+
 func injectHostArgs(hosts []string, cmd *cobra.Command) {
 	cmd.ValidArgs = hosts
 	cmd.Args = cobra.OnlyValidArgs
@@ -1,36 +1,124 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"os"
+	"strings"
 
-var runParallel = 0
+	"github.com/digineo/zackup/app"
+	"github.com/digineo/zackup/config"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runParallel  = 0
+	runFull      = false
+	runDryRun    = false
+	runWithHooks = false
+)
 
 // runCmd represents the run command.
 var runCmd = &cobra.Command{
-	Use:   "run [host [...]]",
+	Use:   "run [host|@group [...]]",
 	Short: "Creates backups and stores them in a local per-host ZFS dataset",
+	Long: `Creates backups and stores them in a local per-host ZFS dataset.
+
+With --dry-run, rsync only reports what would be transferred, and a
+summary of added, changed and deleted files per top-level include path
+is printed. Neither snapshots are created, nor is the state modified.
+Hook scripts are only executed with --with-hooks.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		requireValidConfig()
+
 		if runParallel > 0 {
 			queue.Resize(runParallel)
 		}
 
+		// paused hosts are skipped, unless explicitly named (i.e. not
+		// selected via @group)
+		explicit := make(map[string]bool, len(args))
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "@") {
+				explicit[arg] = true
+			}
+		}
 		if len(args) == 0 {
 			args = tree.Hosts()
+		} else {
+			args = expandHostArgs(args)
 		}
 
+		failed := false
 		for _, host := range args {
 			job := tree.Host(host)
 			if job == nil {
 				log.WithField("job", host).Warn("unknown host, ignoring")
 				continue
 			}
-			queue.Enqueue(job)
+			if !explicit[host] && app.HostPaused(host) {
+				log.WithField("job", host).Info("host is paused or disabled, skipping")
+				continue
+			}
+			opts := app.RunOptions{Full: runFull, WithHooks: runWithHooks}
+			if runDryRun {
+				if !dryRun(job, opts) {
+					failed = true
+				}
+				continue
+			}
+			queue.Enqueue(job, opts)
 		}
 		queue.Wait()
+		if failed {
+			os.Exit(1)
+		}
 	},
 }
 
+// dryRun performs a dry run for job, and prints its summary. It reports
+// whether the dry run succeeded.
+func dryRun(job *config.JobConfig, opts app.RunOptions) bool {
+	stats, err := app.DryRun(job, opts)
+	if err != nil {
+		log.WithField("job", job.Host()).WithError(err).Error("dry run failed")
+		return false
+	}
+
+	total := app.DryRunStats{Path: "total"}
+	longest := len(total.Path)
+	for _, s := range stats {
+		if l := len(s.Path); l > longest {
+			longest = l
+		}
+		total.Added.Files += s.Added.Files
+		total.Added.Bytes += s.Added.Bytes
+		total.Changed.Files += s.Changed.Files
+		total.Changed.Bytes += s.Changed.Bytes
+		total.Deleted.Files += s.Deleted.Files
+		total.Deleted.Bytes += s.Deleted.Bytes
+	}
+
+	fmt.Println(job.Host())
+	for _, s := range append(stats, total) {
+		fmt.Printf("  %-[1]*s  added %s  changed %s  deleted %s\n", longest, s.Path,
+			changeStats(s.Added), changeStats(s.Changed), changeStats(s.Deleted))
+	}
+	return true
+}
+
+func changeStats(c app.ChangeStats) string {
+	return fmt.Sprintf("%6d files (%8s)", c.Files, humanize.Bytes(c.Bytes))
+}
+
 func init() { //nolint:gochecknoinits
 	rootCmd.AddCommand(runCmd)
 	runCmd.PersistentFlags().IntVarP(&runParallel, "parallel", "P", 0,
 		"Run at most `N` jobs parallel (overrides service config value, if > 0)")
+	runCmd.PersistentFlags().BoolVarP(&runFull, "full", "", runFull,
+		"Force a full run (compare checksums instead of size and mtime)")
+	runCmd.PersistentFlags().BoolVarP(&runDryRun, "dry-run", "n", runDryRun,
+		"Only report what would be transferred, without creating snapshots")
+	runCmd.PersistentFlags().BoolVarP(&runWithHooks, "with-hooks", "", runWithHooks,
+		"Execute the hook scripts in dry runs")
 }
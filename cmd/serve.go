@@ -3,6 +3,7 @@ package cmd
 import (
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
 	"github.com/digineo/zackup/app"
@@ -10,7 +11,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var listenAddress = "127.0.0.1:3000"
+var (
+	listenAddress = "127.0.0.1:3000"
+	watchConfig   = false
+)
 
 // serveCmd represents the serve command.
 var serveCmd = &cobra.Command{
@@ -18,6 +22,8 @@ var serveCmd = &cobra.Command{
 	Short: "Starts zackup as deamon.",
 	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, _ []string) {
+		requireValidConfig()
+
 		log.WithFields(logrus.Fields{
 			"listen": listenAddress,
 		}).Info("Start HTTP server")
@@ -28,16 +34,65 @@ var serveCmd = &cobra.Command{
 		srv := app.NewHTTP(listenAddress)
 		go srv.Start()
 
+		var watcher app.Watcher
+		if watchConfig {
+			w, err := app.NewWatcher(tree.Root(), reloadConfig)
+			if err != nil {
+				log.WithError(err).Fatal("failed to watch config tree")
+			}
+			watcher = w
+			go watcher.Start()
+		}
+
 		ch := make(chan os.Signal, 1)
-		signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
-		log.WithField("signal", (<-ch).String()).Warn("Stopping HTTP server")
+		signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		for sig := range ch {
+			if sig == syscall.SIGHUP {
+				log.WithField("signal", sig.String()).Info("Reloading config tree")
+				reloadConfig()
+				continue
+			}
+			log.WithField("signal", sig.String()).Warn("Stopping HTTP server")
+			break
+		}
+		if watcher != nil {
+			watcher.Stop()
+		}
 		sched.Stop()
 		srv.Stop()
 		log.Info("Shutdown.")
 	},
 }
 
+var reloadMu sync.Mutex
+
+// reloadConfig re-reads the config tree and applies it to the running
+// daemon. An invalid config is rejected, the previous one is kept.
+func reloadConfig() {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if err := tree.Reload(); err != nil {
+		log.WithError(err).Error("config reload failed, keeping previous config")
+		return
+	}
+	logFindings()
+
+	svc := tree.Service()
+	if verbosity == 0 {
+		gl.SetLevel(svc.LogLevel)
+	}
+	queue.Resize(int(svc.Parallel))
+
+	if err := app.ReloadState(); err != nil {
+		log.WithError(err).Error("state reconciliation failed")
+		return
+	}
+	log.WithField("hosts", len(tree.Hosts())).Info("config tree reloaded")
+}
+
 func init() { //nolint:gochecknoinits
 	rootCmd.AddCommand(serveCmd)
 	serveCmd.PersistentFlags().StringVarP(&listenAddress, "listen", "l", listenAddress, "`address` to listen on")
+	serveCmd.PersistentFlags().BoolVarP(&watchConfig, "watch", "w", watchConfig, "reload config tree automatically on changes (SIGHUP always works)")
 }
@@ -39,6 +39,10 @@ func colorize(s app.MetricStatus) string {
 		color = "1;31" // red
 	case app.StatusRunning:
 		color = "0;34" // blue
+	case app.StatusPaused:
+		color = "0;33" // yellow
+	case app.StatusSkipped:
+		color = "0;35" // magenta
 	}
 	if color != "" {
 		return fmt.Sprintf("\033[%sm%s\033[0m", color, s.String())
@@ -49,7 +53,7 @@ func colorize(s app.MetricStatus) string {
 // statusCmd represents the status command. Its Run func deals mostly
 // with output formatting.
 var statusCmd = &cobra.Command{
-	Use:   "status",
+	Use:   "status [host|@group [...]]",
 	Short: "Prints a list of hosts and their backup status",
 	Run: func(cmd *cobra.Command, args []string) {
 		if verbosity > 2 {
@@ -61,7 +65,7 @@ var statusCmd = &cobra.Command{
 
 		wantAll := len(args) == 0
 		wantOnly := make(map[string]bool)
-		for _, host := range args {
+		for _, host := range expandHostArgs(args) {
 			wantOnly[host] = true
 		}
 
@@ -83,23 +87,58 @@ var statusCmd = &cobra.Command{
 
 			s := host.Status()
 			fmt.Printf("%-[1]*s  status            %s\n", longest, host.Host, colorize(s))
+			switch {
+			case host.Disabled:
+				fmt.Printf("%s  paused            disabled in config\n", ws)
+			case host.Paused && host.PausedUntil == nil:
+				fmt.Printf("%s  paused            indefinitely\n", ws)
+			case host.Paused && s == app.StatusPaused:
+				fmt.Printf("%s  paused until      %s\n", ws, statusTime(host.PausedUntil))
+			}
 
 			// we don't know anything yet if primed
-			if s != app.StatusPrimed {
+			if s != app.StatusPrimed && (s != app.StatusPaused || !host.StartedAt.IsZero()) {
 				if s == app.StatusUnknown || s == app.StatusRunning {
 					fmt.Printf("%s  started           %s\n", ws, statusTime(&host.StartedAt))
 				}
-				if s == app.StatusUnknown || s == app.StatusSuccess {
+				if s == app.StatusUnknown || s == app.StatusPaused || s == app.StatusSuccess {
 					t := statusTime(host.SucceededAt)
 					d := statusDur(host.SuccessDuration)
 					fmt.Printf("%s  succeeded at      %s (took %s)\n", ws, t, d)
 				}
-				if s == app.StatusUnknown || s == app.StatusFailed {
+				if s == app.StatusUnknown || s == app.StatusPaused || s == app.StatusFailed {
 					t := statusTime(host.FailedAt)
 					d := statusDur(host.FailureDuration)
 					fmt.Printf("%s  failed at         %s (took %s)\n", ws, t, d)
 				}
 
+				if s == app.StatusSkipped {
+					fmt.Printf("%s  skipped at        %s\n", ws, statusTime(host.SkippedAt))
+				}
+
+				for i, r := range host.Scripts {
+					label := "scripts"
+					if i > 0 {
+						label = ""
+					}
+					fmt.Printf("%s  %-17s %s\n", ws, label, r)
+				}
+				for i, r := range host.Streams {
+					label := "streams"
+					if i > 0 {
+						label = ""
+					}
+					fmt.Printf("%s  %-17s %s\n", ws, label, r)
+				}
+
+				if host.PartialBytes > 0 {
+					fmt.Printf("%s  partial files     %s\n", ws, humanize.Bytes(host.PartialBytes))
+				}
+				if host.LastFullAt != nil {
+					fmt.Printf("%s  last full run     %s (%d incremental runs since)\n", ws,
+						statusTime(host.LastFullAt), host.IncrementalRuns)
+				}
+
 				fmt.Printf("%s  space used        %s (%s snapshots, %s dataset, %s children, %s refreservation)\n", ws,
 					humanize.Bytes(host.SpaceUsedTotal()),
 					humanize.Bytes(host.SpaceUsedBySnapshots),
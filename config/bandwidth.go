@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// BandwidthConfig limits the transfer rate of rsync. Since rsync cannot
+// change its limit while running, the limit is determined once, when
+// the rsync process is started.
+type BandwidthConfig struct {
+	// Limit applies, if no window matches. Zero means unlimited.
+	Limit Rate `yaml:"limit"`
+
+	// Windows define limits for certain times of the day. The first
+	// matching window wins.
+	Windows []BandwidthWindow `yaml:"windows"`
+
+	// Group names a bandwidth group (see ServiceConfig.BandwidthGroups),
+	// whose aggregate limit is shared with other running jobs of the
+	// same group.
+	Group string `yaml:"group"`
+}
+
+// BandwidthWindow is a time-of-day range with its own limit. If From is
+// after To, the window wraps around midnight.
+type BandwidthWindow struct {
+	From  timeOfDay `yaml:"from"`
+	To    timeOfDay `yaml:"to"`
+	Limit Rate      `yaml:"limit"`
+}
+
+// LimitAt returns the limit applicable at the given time. Zero means
+// unlimited.
+func (b *BandwidthConfig) LimitAt(t time.Time) Rate {
+	if b == nil {
+		return 0
+	}
+	for _, w := range b.Windows {
+		if w.contains(t) {
+			return w.Limit
+		}
+	}
+	return b.Limit
+}
+
+func (w *BandwidthWindow) contains(t time.Time) bool {
+	h, m, s := t.Clock()
+	now := timeOfDay(h*3600 + m*60 + s)
+
+	if w.From <= w.To {
+		return w.From <= now && now < w.To
+	}
+	return w.From <= now || now < w.To
+}
+
+// Rate is a transfer rate in bytes per second. In YAML, it is either
+// a plain number (bytes per second) or a string with unit, e.g. "2 MB/s",
+// "500k" or "1MiB". Zero means unlimited.
+type Rate uint64
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (r *Rate) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var n uint64
+	if err := unmarshal(&n); err == nil {
+		*r = Rate(n)
+		return nil
+	}
+
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	n, err := humanize.ParseBytes(strings.TrimSuffix(strings.TrimSpace(s), "/s"))
+	if err != nil {
+		return fmt.Errorf("invalid rate %q: %w", s, err)
+	}
+	*r = Rate(n)
+	return nil
+}
+
+// KiB returns the rate in KiB/s (rsync's --bwlimit unit), rounded up.
+func (r Rate) KiB() uint64 {
+	return (uint64(r) + 1023) / 1024
+}
+
+func (r Rate) String() string {
+	if r == 0 {
+		return "unlimited"
+	}
+	return humanize.IBytes(uint64(r)) + "/s"
+}
+
+// timeOfDay is the number of seconds since midnight.
+type timeOfDay int
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface. It accepts
+// "HH:MM" and "HH:MM:SS" (24h clock). The value "24:00" is allowed to
+// denote the end of the day.
+func (tod *timeOfDay) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	f := strings.Split(s, ":")
+	if len(f) < 2 || len(f) > 3 {
+		return fmt.Errorf("invalid time of day %q, expected HH:MM[:SS]", s)
+	}
+
+	secs := 0
+	for pos, mul := range []int{3600, 60, 1}[:len(f)] {
+		val, err := strconv.Atoi(f[pos])
+		if err != nil || val < 0 || (pos == 0 && val > 24) || (pos > 0 && val > 59) {
+			return fmt.Errorf("invalid time of day %q", s)
+		}
+		secs += val * mul
+	}
+	if secs > 24*3600 {
+		return fmt.Errorf("invalid time of day %q", s)
+	}
+
+	*tod = timeOfDay(secs)
+	return nil
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (tod timeOfDay) MarshalYAML() (interface{}, error) {
+	if tod%60 != 0 {
+		return fmt.Sprintf("%s:%02d", tod.String(), tod%60), nil
+	}
+	return tod.String(), nil
+}
+
+func (tod timeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", tod/3600, tod%3600/60)
+}
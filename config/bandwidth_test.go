@@ -0,0 +1,88 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const bandwidthYAML = `
+bandwidth:
+  limit: 10MiB/s
+  group: office
+  windows:
+  - from: "07:00"
+    to: "19:00"
+    limit: 2 MB/s
+  - from: "23:00"
+    to: "01:30"
+    limit: 0
+`
+
+func TestBandwidthUnmarshal(t *testing.T) {
+	var j JobConfig
+	if err := yaml.Unmarshal([]byte(bandwidthYAML), &j); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.New(t).Equal(&BandwidthConfig{
+		Limit: 10 << 20,
+		Group: "office",
+		Windows: []BandwidthWindow{
+			{From: 7 * 3600, To: 19 * 3600, Limit: 2000000},
+			{From: 23 * 3600, To: 1*3600 + 30*60, Limit: 0},
+		},
+	}, j.Bandwidth)
+
+	for _, invalid := range []string{
+		"bandwidth: {limit: 2 parsecs}",
+		"bandwidth: {windows: [{from: '7', to: '19:00'}]}",
+		"bandwidth: {windows: [{from: '07:00', to: '25:00'}]}",
+	} {
+		assert.New(t).Error(yaml.Unmarshal([]byte(invalid), &JobConfig{}), invalid)
+	}
+}
+
+func TestBandwidthLimitAt(t *testing.T) {
+	var j JobConfig
+	if err := yaml.Unmarshal([]byte(bandwidthYAML), &j); err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(h, m int) time.Time {
+		return time.Date(2018, time.December, 9, h, m, 0, 0, time.Local)
+	}
+
+	tests := map[string]struct {
+		t        time.Time
+		expected Rate
+	}{
+		"before":        {at(6, 59), 10 << 20},
+		"window start":  {at(7, 0), 2000000},
+		"window":        {at(12, 0), 2000000},
+		"window end":    {at(19, 0), 10 << 20},
+		"wrap.before":   {at(22, 59), 10 << 20},
+		"wrap.evening":  {at(23, 0), 0},
+		"wrap.midnight": {at(0, 0), 0},
+		"wrap.end":      {at(1, 30), 10 << 20},
+	}
+
+	for name := range tests {
+		tc := tests[name]
+		t.Run(name, func(t *testing.T) {
+			assert.New(t).Equal(tc.expected, j.Bandwidth.LimitAt(tc.t))
+		})
+	}
+
+	var unset *BandwidthConfig
+	assert.New(t).Equal(Rate(0), unset.LimitAt(at(12, 0)))
+}
+
+func TestRateKiB(t *testing.T) {
+	assert.New(t).EqualValues(0, Rate(0).KiB())
+	assert.New(t).EqualValues(1, Rate(1).KiB())
+	assert.New(t).EqualValues(1, Rate(1024).KiB())
+	assert.New(t).EqualValues(1954, Rate(2000000).KiB())
+}
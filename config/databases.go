@@ -0,0 +1,217 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Database types.
+const (
+	DatabasePostgres = "postgres"
+	DatabaseMySQL    = "mysql"
+)
+
+// AllDatabases in DatabaseConfig.Databases selects all databases.
+const AllDatabases = "all"
+
+// DumpIncompleteExitCode is the exit code of a dump script, if the dump
+// lacks its trailer (i.e. the dump tool has failed or was interrupted).
+const DumpIncompleteExitCode = 3
+
+// GlobalsDump is the base name of the file containing the globals (i.e.
+// roles and tablespaces for PostgreSQL, and the "mysql" system database
+// for MySQL).
+const GlobalsDump = "_globals"
+
+// DatabaseConfig describes database dumps, which are streamed into
+// StreamsDir/Name/ (see StreamConfig).
+type DatabaseConfig struct {
+	// Type is one of "postgres" or "mysql".
+	Type string `yaml:"type"`
+
+	// Name of the subdirectory in StreamsDir. Defaults to Type, must be
+	// unique within a host.
+	Name string `yaml:"name"`
+
+	// Databases to dump, each into its own file. "all" selects all
+	// databases (except templates and system schemas). Defaults to "all".
+	Databases []string `yaml:"databases"`
+
+	// User connects to the database server as this user. Passwords can
+	// be provided via secrets (PGPASSWORD, MYSQL_PWD).
+	User string `yaml:"user"`
+
+	// Socket is the socket directory (PostgreSQL) or socket file (MySQL).
+	Socket string `yaml:"socket"`
+
+	// Compress is empty, "zstd" or "gzip". Compression happens on the
+	// remote host.
+	Compress string `yaml:"compress"`
+}
+
+// compressors maps DatabaseConfig.Compress to command and file suffix.
+var compressors = map[string]struct{ cmd, ext string }{
+	"":     {"", ""},
+	"zstd": {"zstd -q -c", ".zst"},
+	"gzip": {"gzip -c", ".gz"},
+}
+
+// DirName returns the name of the subdirectory in StreamsDir.
+func (d *DatabaseConfig) DirName() string {
+	if d.Name == "" {
+		return d.Type
+	}
+	return d.Name
+}
+
+// DumpsAll reports whether all databases should be dumped, i.e. the
+// list of databases needs to be queried from the server (see ListScript).
+func (d *DatabaseConfig) DumpsAll() bool {
+	return len(d.Databases) == 0 || contains(d.Databases, AllDatabases)
+}
+
+// File returns the file name (relative to StreamsDir) of the dump of db.
+func (d *DatabaseConfig) File(db string) string {
+	return d.DirName() + "/" + db + ".sql" + compressors[d.Compress].ext
+}
+
+// ListScript returns a script printing the names of all databases.
+func (d *DatabaseConfig) ListScript() []string {
+	if d.Type == DatabasePostgres {
+		query := "SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate ORDER BY 1"
+		return []string{"psql" + d.connOpts() + " -AtX -d postgres -c " + ShellQuote(query)}
+	}
+	return []string{"mysql" + d.connOpts() + " -NB -e 'SHOW DATABASES'"}
+}
+
+// ParseList parses the output of ListScript. System databases and names
+// not usable as file names are skipped.
+func (d *DatabaseConfig) ParseList(out string) (dbs, skipped []string) {
+	for _, name := range strings.Split(out, "\n") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+		case d.Type == DatabaseMySQL && contains(mysqlSystemDatabases, name):
+		case !isDumpName(name):
+			skipped = append(skipped, name)
+		default:
+			dbs = append(dbs, name)
+		}
+	}
+	return dbs, skipped
+}
+
+// mysqlSystemDatabases are not dumped with "all". The "mysql" database
+// is part of the globals dump.
+var mysqlSystemDatabases = []string{"information_schema", "performance_schema", "sys", "mysql"}
+
+// DumpScript returns a script dumping db to stdout. The script fails
+// with DumpIncompleteExitCode, if the dump lacks its trailer.
+func (d *DatabaseConfig) DumpScript(db string) []string {
+	if d.Type == DatabasePostgres {
+		return d.checkedDump("pg_dump"+d.connOpts()+" "+ShellQuote(db),
+			"-- PostgreSQL database dump complete")
+	}
+	return d.checkedDump("mysqldump"+d.connOpts()+
+		" --single-transaction --routines --events --triggers --databases "+ShellQuote(db),
+		"-- Dump completed")
+}
+
+// GlobalsScript returns a script dumping the globals to stdout.
+func (d *DatabaseConfig) GlobalsScript() []string {
+	if d.Type == DatabasePostgres {
+		return d.checkedDump("pg_dumpall"+d.connOpts()+" --globals-only",
+			"-- PostgreSQL database cluster dump complete")
+	}
+	return d.checkedDump("mysqldump"+d.connOpts()+" --flush-privileges --databases mysql",
+		"-- Dump completed")
+}
+
+// trailerSize is the number of bytes at the end of a dump, which are
+// searched for the trailer. pg_dump 17.6 and newer write an "\unrestrict"
+// line after it.
+const trailerSize = 1024
+
+// checkedDump pipes the output of cmd through tee into the compressor
+// (if any), and checks the last trailerSize bytes (captured by tail(1)
+// via a FIFO) for the trailer. This works without "set -o pipefail",
+// and without parsing the whole dump.
+func (d *DatabaseConfig) checkedDump(cmd, trailer string) []string {
+	pipe := cmd + ` | tee "$d/dump"`
+	if c := compressors[d.Compress].cmd; c != "" {
+		pipe += " | " + c
+	}
+	return []string{
+		`d=$(mktemp -d)`,
+		`trap 'rm -rf "$d"' EXIT`,
+		`mkfifo "$d/dump"`,
+		fmt.Sprintf(`tail -c %d "$d/dump" > "$d/tail" &`, trailerSize),
+		`pid=$!`,
+		pipe,
+		`wait $pid`,
+		fmt.Sprintf(`grep -qF -e %s "$d/tail" || exit %d`, ShellQuote(trailer), DumpIncompleteExitCode),
+	}
+}
+
+func (d *DatabaseConfig) connOpts() string {
+	var opts string
+	if d.User != "" {
+		if d.Type == DatabasePostgres {
+			opts += " -U " + ShellQuote(d.User)
+		} else {
+			opts += " -u " + ShellQuote(d.User)
+		}
+	}
+	if d.Socket != "" {
+		if d.Type == DatabasePostgres {
+			opts += " -h " + ShellQuote(d.Socket)
+		} else {
+			opts += " -S " + ShellQuote(d.Socket)
+		}
+	}
+	return opts
+}
+
+// plainName matches names usable as file name, i.e. of databases and
+// streams. It excludes the separators of encoded stream results (see
+// app.encodeStreams).
+var plainName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// isDumpName checks whether a database name is usable as file name.
+func isDumpName(name string) bool {
+	return name != "." && name != ".." && name != GlobalsDump && plainName.MatchString(name)
+}
+
+// checkDatabases returns problems with the given database configs.
+func checkDatabases(dbs []DatabaseConfig) (problems []string) {
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	seen := make(map[string]bool)
+	for i, d := range dbs {
+		if d.Type != DatabasePostgres && d.Type != DatabaseMySQL {
+			add("unknown databases[%d].type %q, expected postgres or mysql", i, d.Type)
+		}
+		if name := d.DirName(); !isDumpName(name) {
+			add("databases[%d].name %q must be a plain file name", i, name)
+		} else if seen[name] {
+			add("databases[%d].name %q is not unique", i, name)
+		}
+		seen[d.DirName()] = true
+
+		if _, ok := compressors[d.Compress]; !ok {
+			add("unknown databases[%d].compress %q, expected zstd or gzip", i, d.Compress)
+		}
+		for _, db := range d.Databases {
+			if db != AllDatabases && !isDumpName(db) {
+				add("databases[%d].databases: invalid name %q", i, db)
+			}
+		}
+		if contains(d.Databases, AllDatabases) && len(d.Databases) > 1 {
+			add("databases[%d].databases: %q cannot be combined with other names", i, AllDatabases)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseScripts(t *testing.T) {
+	assert := assert.New(t)
+
+	pg := &DatabaseConfig{Type: DatabasePostgres, User: "postgres", Socket: "/run/postgresql", Compress: "zstd"}
+	assert.True(pg.DumpsAll())
+	assert.Equal("postgres/app.sql.zst", pg.File("app"))
+	assert.Equal([]string{`psql -U 'postgres' -h '/run/postgresql' -AtX -d postgres -c ` +
+		`'SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate ORDER BY 1'`}, pg.ListScript())
+	assert.Contains(pg.DumpScript("it's"),
+		`pg_dump -U 'postgres' -h '/run/postgresql' 'it'\''s' | tee "$d/dump" | zstd -q -c`)
+	assert.Contains(pg.DumpScript("it's"), `grep -qF -e '-- PostgreSQL database dump complete' "$d/tail" || exit 3`)
+	assert.Contains(pg.GlobalsScript(), `pg_dumpall -U 'postgres' -h '/run/postgresql' --globals-only | tee "$d/dump" | zstd -q -c`)
+
+	my := &DatabaseConfig{Type: DatabaseMySQL, Name: "mariadb", Databases: []string{"shop"}}
+	assert.False(my.DumpsAll())
+	assert.Equal("mariadb/shop.sql", my.File("shop"))
+	assert.Contains(my.DumpScript("shop"),
+		`mysqldump --single-transaction --routines --events --triggers --databases 'shop' | tee "$d/dump"`)
+
+	dbs, skipped := my.ParseList("information_schema\nmysql\nshop\nwiki_2\nfoo/bar\na,b\nx=y\nmy db\n")
+	assert.Equal([]string{"shop", "wiki_2"}, dbs)
+	assert.Equal([]string{"foo/bar", "a,b", "x=y", "my db"}, skipped)
+}
+
+func TestDatabaseCheckedDump(t *testing.T) {
+	run := func(d *DatabaseConfig, output string) ([]byte, int) {
+		script := d.checkedDump("printf "+ShellQuote(output), "-- Dump completed")
+		cmd := exec.Command("/bin/sh", "-ec", strings.Join(script, "\n"))
+		out, err := cmd.Output()
+		if err != nil {
+			return out, err.(*exec.ExitError).ExitCode()
+		}
+		return out, 0
+	}
+
+	plain := &DatabaseConfig{Type: DatabaseMySQL}
+	out, code := run(plain, "CREATE TABLE t;\n-- Dump completed on 2006-01-02\n")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "CREATE TABLE t;\n-- Dump completed on 2006-01-02\n", string(out))
+
+	_, code = run(plain, "CREATE TABLE t;\n")
+	assert.Equal(t, DumpIncompleteExitCode, code)
+
+	// the trailer must be within the last trailerSize bytes
+	_, code = run(plain, "-- Dump completed on 2006-01-02\n"+strings.Repeat("x", trailerSize))
+	assert.Equal(t, DumpIncompleteExitCode, code)
+
+	gz := &DatabaseConfig{Type: DatabaseMySQL, Compress: "gzip"}
+	out, code = run(gz, "CREATE TABLE t;\n-- Dump completed on 2006-01-02\n")
+	require.Equal(t, 0, code)
+	r, err := gzip.NewReader(bytes.NewReader(out))
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE t;\n-- Dump completed on 2006-01-02\n", string(data))
+}
+
+func TestCheckDatabases(t *testing.T) {
+	assert.Equal(t, []string{
+		`unknown databases[0].type "oracle", expected postgres or mysql`,
+		`databases[1].name "mysql" is not unique`,
+		`unknown databases[1].compress "xz", expected zstd or gzip`,
+		`databases[1].databases: invalid name "a/b"`,
+		`databases[2].name "../x" must be a plain file name`,
+		`databases[2].databases: "all" cannot be combined with other names`,
+	}, checkDatabases([]DatabaseConfig{
+		{Type: "oracle", Name: "mysql"},
+		{Type: DatabaseMySQL, Compress: "xz", Databases: []string{"a/b"}},
+		{Type: DatabasePostgres, Name: "../x", Databases: []string{"all", "app"}},
+	}))
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// decodeFile reads the YAML file name into v. Unknown keys are ignored
+// (as they always have been), but to detect typos, the file is decoded
+// a second time in strict mode, and the resulting problems are returned.
+func decodeFile(name string, v interface{}) (problems []string, err error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	if err = yaml.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	// decode into a fresh value of the same type
+	dup := reflect.New(reflect.TypeOf(v).Elem()).Interface()
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.SetStrict(true)
+
+	var typeErr *yaml.TypeError
+	if err := dec.Decode(dup); errors.As(err, &typeErr) {
+		for _, msg := range typeErr.Errors {
+			problems = append(problems, strings.TrimSpace(msg))
+		}
+	} else if err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems, nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// originDefault marks values neither set in the host nor global config.
+const originDefault = "default"
+
+// Describe renders the merged config for a host as YAML. Each value is
+// annotated with a comment naming the file it came from (either the host
+// config file, a group config, globals.yml or the hook script files).
+func (t *tree) Describe(host string) ([]byte, error) {
+	t.RLock()
+	raw, ok := t.hosts[host]
+	var layers []*JobConfig
+	if ok {
+		layers = t.layers(raw)
+	}
+	root := t.root
+	t.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unknown host %q", host)
+	}
+	job := t.Host(host)
+
+	// collect files by decreasing precedence
+	o := &origins{name: host}
+	files := []string{raw.file}
+	for i := len(layers) - 1; i >= 0; i-- {
+		files = append(files, layers[i].file)
+	}
+	for _, file := range files {
+		node, err := readYAMLNode(path.Join(root, file))
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %q", file)
+		}
+		o.files = append(o.files, file)
+		o.nodes = append(o.nodes, node)
+	}
+
+	var merged yamlv3.Node
+	if err := merged.Encode(job); err != nil {
+		return nil, errors.Wrap(err, "encoding config")
+	}
+	o.annotate(&merged, o.nodes)
+
+	// scripts are merged from multiple sources, annotate them separately
+	for _, hook := range job.Hooks() {
+		for i := 0; i+1 < len(merged.Content); i += 2 {
+			if key := merged.Content[i]; key.Value == hook.Key {
+				merged.Content[i+1].LineComment = o.scriptOrigin(hook.Key, *hook.Script, hook.Pattern)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# merged config for %s\n", host)
+	enc := yamlv3.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&merged); err != nil {
+		return nil, errors.Wrap(err, "encoding config")
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// readYAMLNode parses a YAML file into a node tree. It returns nil for
+// empty files.
+func readYAMLNode(name string) (*yamlv3.Node, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	var doc yamlv3.Node
+	if err = yamlv3.Unmarshal(data, &doc); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if doc.Kind != yamlv3.DocumentNode || len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return doc.Content[0], nil
+}
+
+type origins struct {
+	name  string         // host name
+	files []string       // config files, by decreasing precedence
+	nodes []*yamlv3.Node // parsed files, same order
+}
+
+// annotate walks the merged node tree n, and looks up the corresponding
+// nodes in the config files to determine the origin of each value.
+func (o *origins) annotate(n *yamlv3.Node, layers []*yamlv3.Node) {
+	switch n.Kind {
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			children := make([]*yamlv3.Node, len(layers))
+			for l, layer := range layers {
+				children[l] = lookupKey(layer, key.Value)
+			}
+
+			if val.Kind == yamlv3.ScalarNode || val.Kind == yamlv3.SequenceNode && len(val.Content) == 0 {
+				val.LineComment = o.origin(children)
+				continue
+			}
+			o.annotate(val, children)
+		}
+
+	case yamlv3.SequenceNode:
+		// merged lists contain the items of each layer, in order of
+		// decreasing precedence (unless cut by override_global_*)
+		i := 0
+		for l, layer := range layers {
+			if layer == nil || layer.Kind != yamlv3.SequenceNode {
+				continue
+			}
+			for range layer.Content {
+				if i < len(n.Content) {
+					n.Content[i].LineComment = o.files[l]
+					i++
+				}
+			}
+		}
+
+	default:
+		n.LineComment = o.origin(layers)
+	}
+}
+
+// origin returns the file name of the first layer defining a value.
+func (o *origins) origin(layers []*yamlv3.Node) string {
+	for l, layer := range layers {
+		if layer != nil && layer.Tag != "!!null" {
+			return o.files[l]
+		}
+	}
+	return originDefault
+}
+
+// scriptOrigin lists the sources of a merged script, in execution order.
+func (o *origins) scriptOrigin(key string, s Script, pattern string) string {
+	var sources []string
+	for l := len(o.nodes) - 1; l >= 0; l-- {
+		if v := lookupKey(o.nodes[l], key); v != nil && v.Value != "" {
+			sources = append(sources, o.files[l])
+		}
+	}
+	if len(s.scripts) > 0 {
+		sources = append(sources, path.Join(o.name, pattern))
+	}
+	if len(sources) == 0 {
+		return originDefault
+	}
+	return strings.Join(sources, ", ")
+}
+
+func lookupKey(n *yamlv3.Node, key string) *yamlv3.Node {
+	if n == nil || n.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
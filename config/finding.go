@@ -0,0 +1,220 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Finding describes a problem found while loading the config tree.
+// Findings are not fatal by themselves, unless the service runs in
+// strict mode (see ServiceConfig.Strict).
+type Finding struct {
+	File    string // config file, relative to the tree root
+	Host    string // empty for the global config
+	Message string
+}
+
+func (f Finding) String() string {
+	if f.Host == "" {
+		return fmt.Sprintf("%s: %s", f.File, f.Message)
+	}
+	return fmt.Sprintf("%s (host %s): %s", f.File, f.Host, f.Message)
+}
+
+// validate checks j (before merging global values) for problems. The
+// given file name is used as context for the findings.
+func (j *JobConfig) validate(file string, svc *ServiceConfig) (findings []Finding) {
+	add := func(format string, args ...interface{}) {
+		findings = append(findings, Finding{File: file, Host: j.host, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, problem := range j.problems {
+		add("%s", problem)
+	}
+	if j.host != "" && !isValidHostname(j.host) {
+		add("host name %q is not a valid DNS name", j.host)
+	}
+	if j.SSH != nil && strings.ContainsAny(j.SSH.User, " \t@") {
+		add("invalid ssh.user %q", j.SSH.User)
+	}
+	if j.RSync != nil {
+		for _, list := range []struct {
+			name  string
+			items []string
+		}{{"include", j.RSync.Included}, {"exclude", j.RSync.Excluded}} {
+			for i, item := range list.items {
+				if strings.TrimSpace(item) == "" {
+					add("rsync.%s[%d] is empty", list.name, i)
+				}
+			}
+		}
+		for _, problem := range j.RSync.checkArgs() {
+			add("%s", problem)
+		}
+	}
+	for _, problem := range j.checkTransport() {
+		add("%s", problem)
+	}
+	if j.WakeOnLAN != nil {
+		for _, problem := range j.WakeOnLAN.check() {
+			add("%s", problem)
+		}
+	}
+	if j.SourceSnapshot != nil {
+		for _, problem := range j.SourceSnapshot.check() {
+			add("%s", problem)
+		}
+	}
+	for _, problem := range checkStreams(j.Streams) {
+		add("%s", problem)
+	}
+	for _, problem := range checkDatabases(j.Databases) {
+		add("%s", problem)
+	}
+	for name, val := range j.Secrets {
+		if !secretName.MatchString(name) {
+			add("secret name %q is not a valid environment variable name", name)
+		}
+		for _, problem := range checkRefs(val) {
+			add("secrets.%s: %s", name, problem)
+		}
+	}
+	for _, hook := range j.Hooks() {
+		for _, unit := range hook.Script.Units() {
+			if unit.Template {
+				if err := checkTemplate(unit.Name, unit.Lines); err != nil {
+					add("%s: %v", hook.Key, err)
+				}
+			}
+			for _, line := range unit.Lines {
+				for _, problem := range checkRefs(line) {
+					add("%s: %s", hook.Key, problem)
+				}
+			}
+		}
+	}
+	if bw := j.Bandwidth; bw != nil {
+		for i, w := range bw.Windows {
+			if w.From == w.To {
+				add("bandwidth.windows[%d] is empty (from == to)", i)
+			}
+		}
+		if _, ok := svc.BandwidthGroups[bw.Group]; bw.Group != "" && !ok {
+			add("bandwidth.group %q is not defined in config.yml", bw.Group)
+		}
+	}
+	return findings
+}
+
+// validateMerged checks the merged config of a host for settings, which
+// are not supported by its transport.
+func (j *JobConfig) validateMerged() (findings []Finding) {
+	add := func(format string, args ...interface{}) {
+		findings = append(findings, Finding{File: j.file, Host: j.host, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, problem := range j.checkOpportunistic() {
+		add("%s", problem)
+	}
+
+	transport := j.TransportType()
+	if j.SourceSnapshot != nil && transport != TransportSSH {
+		add("source_snapshot is not supported with transport %s", transport)
+	}
+	if w := j.WakeOnLAN; w != nil {
+		if transport == TransportLocal || transport == TransportPush {
+			add("wake_on_lan is not supported with transport %s", transport)
+		} else if w.Shutdown != "" && !j.HasShell() {
+			add("wake_on_lan.shutdown is not supported with transport %s", transport)
+		}
+	}
+	if !j.HasShell() {
+		for _, hook := range j.Hooks() {
+			if strings.HasPrefix(hook.Key, "local_") {
+				continue
+			}
+			if len(hook.Script.Units()) > 0 {
+				add("%s is not supported with transport %s", hook.Key, transport)
+			}
+		}
+	}
+	if j.HasShell() && !j.IsPush() {
+		return findings
+	}
+	if len(j.Streams) > 0 {
+		add("streams are not supported with transport %s", transport)
+	}
+	if len(j.Databases) > 0 {
+		add("databases are not supported with transport %s", transport)
+	}
+	return findings
+}
+
+var logLevels = []string{"panic", "fatal", "error", "warn", "warning", "info", "debug", "trace"}
+
+// validate checks s for problems. problems are passed from decodeFile().
+func (s *ServiceConfig) validate(file string, problems []string) (findings []Finding) {
+	add := func(format string, args ...interface{}) {
+		findings = append(findings, Finding{File: file, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, problem := range problems {
+		add("%s", problem)
+	}
+	if s.Parallel == 0 {
+		add("parallel must be greater than 0")
+	}
+	if s.RootDataset == "" || strings.HasPrefix(s.RootDataset, "/") {
+		add("invalid root_dataset %q", s.RootDataset)
+	}
+	if !path.IsAbs(s.MountBase) {
+		add("mount_base %q must be an absolute path", s.MountBase)
+	}
+	if lvl := strings.ToLower(s.LogLevel); lvl != "" && !contains(logLevels, lvl) {
+		add("unknown log_level %q", s.LogLevel)
+	}
+	for group, limit := range s.BandwidthGroups {
+		if limit == 0 {
+			add("bandwidth_groups.%s is unlimited", group)
+		}
+	}
+	return findings
+}
+
+// isValidHostname checks whether name consists of valid DNS labels.
+func isValidHostname(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Host < findings[j].Host
+	})
+}
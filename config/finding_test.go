@@ -0,0 +1,120 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsValidHostname(t *testing.T) {
+	for _, name := range []string{"example.com", "test-1.example.org", "localhost", "10.0.0.1"} {
+		assert.New(t).True(isValidHostname(name), name)
+	}
+	for _, name := range []string{"", "-example.com", "example-.com", "exa_mple.com", "example..com", strings.Repeat("a", 64)} {
+		assert.New(t).False(isValidHostname(name), name)
+	}
+}
+
+func TestTreeFindings(t *testing.T) {
+	tr := loadTree(t, map[string]string{
+		"config.yml":            "parallel: 2\nroot_dataset: zpool/zackup\nmount_base: /zpool/zackup\nlog_level: chatty\n",
+		"globals.yml":           "ssh:\n  user: root\n  identity_file: id_rsa\nrsync:\n  args: [--partial]\n",
+		"hosts/example.com.yml": "rsync:\n  include: ['']\nbandwidth:\n  group: office\n",
+		"hosts/in_valid.yml":    "---\n",
+	})
+
+	assert.New(t).Equal([]string{
+		`config.yml: unknown log_level "chatty"`,
+		`globals.yml: line 3: field identity_file not found in type config.SSHConfig`,
+		`globals.yml: rsync argument "--partial" is not allowed and will be ignored`,
+		`hosts/example.com.yml (host example.com): rsync.include[0] is empty`,
+		`hosts/example.com.yml (host example.com): bandwidth.group "office" is not defined in config.yml`,
+		`hosts/in_valid.yml (host in_valid): host name "in_valid" is not a valid DNS name`,
+	}, findings(tr))
+}
+
+func TestTreeDescribe(t *testing.T) {
+	tr := loadTree(t, map[string]string{
+		"config.yml":                 "---\n",
+		"globals.yml":                "ssh:\n  user: root\nrsync:\n  include: [/etc]\n",
+		"hosts/test.example.org.yml": "pre_script: echo test.example.org pre inline\n",
+		"test.example.org/pre.a.sh":  "echo test.example.org pre file\n",
+	})
+
+	out, err := tr.Describe("test.example.org")
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		"  user: root # globals.yml",
+		"    - /etc # globals.yml",
+		"pre_script: | # hosts/test.example.org.yml, test.example.org/pre.*.sh",
+		"  echo test.example.org pre file",
+		"full_every: null # default",
+	} {
+		assert.New(t).Contains(string(out), line+"\n")
+	}
+
+	_, err = tr.Describe("unknown.example.com")
+	assert.New(t).Error(err)
+}
+
+func TestTreeReloadKeepsPreviousConfig(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"config.yml":            "parallel: 2\nroot_dataset: zpool/zackup\nmount_base: /zpool/zackup\n",
+		"globals.yml":           "---\n",
+		"hosts/example.com.yml": "---\n",
+	})
+
+	tr := NewTree("")
+	require.NoError(t, tr.SetRoot(root))
+	assert.New(t).Equal([]string{"example.com"}, tr.Hosts())
+
+	// broken YAML is rejected
+	require.NoError(t, os.WriteFile(filepath.Join(root, "hosts/example.org.yml"), []byte("ssh: ["), 0o644))
+	assert.New(t).Error(tr.Reload())
+	assert.New(t).Equal([]string{"example.com"}, tr.Hosts())
+
+	// in strict mode, problems are rejected as well
+	require.NoError(t, os.WriteFile(filepath.Join(root, "hosts/example.org.yml"), []byte("sssh: {}"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "config.yml"), []byte("strict: true\nparallel: 2\nroot_dataset: zpool/zackup\nmount_base: /zpool/zackup\n"), 0o644))
+	assert.New(t).Error(tr.Reload())
+	assert.New(t).Equal([]string{"example.com"}, tr.Hosts())
+	assert.New(t).False(tr.Service().Strict)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "hosts/example.org.yml"), []byte("ssh: {}"), 0o644))
+	assert.New(t).NoError(tr.Reload())
+	assert.New(t).Equal([]string{"example.com", "example.org"}, tr.Hosts())
+}
+
+func TestCheckTree(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"config.yml":            "parallel: 2\nroot_dataset: zpool/zackup\nmount_base: /zpool/zackup\n",
+		"globals.yml":           "ssh: {port: ssh}\n",
+		"groups/office.yml":     "rsync: [",
+		"hosts/example.com.yml": "rsync:\n  filter: ['*.tmp']\n",
+		"hosts/example.org.yml": "ssh:\n  user: root\nsssh: {}\n",
+		"hosts/example.net.yml": "groups: [office]\n",
+	})
+
+	// SetRoot stops at the first broken file
+	assert.New(t).Error(NewTree("").SetRoot(root))
+
+	tr := CheckTree(root)
+	assert.New(t).Equal([]string{"example.net", "example.org"}, tr.Hosts())
+
+	list := findings(tr)
+	require.Len(t, list, 5)
+	for i, prefix := range []string{
+		`globals.yml: failed to load globals.yml: yaml: unmarshal errors:`,
+		`groups/office.yml: reading `,
+		`hosts/example.com.yml (host example.com): reading `,
+		`hosts/example.net.yml (host example.net): unknown group "office"`,
+		`hosts/example.org.yml (host example.org): line 3: field sssh not found`,
+	} {
+		assert.New(t).True(strings.HasPrefix(list[i], prefix), list[i])
+	}
+}
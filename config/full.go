@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FullInterval defines how often a full run (i.e. an rsync with
+// --checksum) is performed. It is either a duration ("7d", "36h") or
+// a plain number N, which results in a full run every N-th run.
+type FullInterval struct {
+	Every time.Duration // full run if the last one is older than this
+	Runs  uint          // full run on every Runs-th run
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (f *FullInterval) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var runs uint
+	if err := unmarshal(&runs); err == nil {
+		*f = FullInterval{Runs: runs}
+		return nil
+	}
+
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	dur, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*f = FullInterval{Every: dur}
+	return nil
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (f FullInterval) MarshalYAML() (interface{}, error) {
+	if f.Runs > 0 {
+		return f.Runs, nil
+	}
+	if f.Every%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", f.Every/(24*time.Hour)), nil
+	}
+	return f.Every.String(), nil
+}
+
+// Due reports whether the next run should be a full run. lastFull is
+// the time of the last successful full run (nil if there was none),
+// and incrRuns is the number of successful incremental runs since then.
+func (f *FullInterval) Due(lastFull *time.Time, incrRuns uint, now time.Time) bool {
+	if f == nil || (f.Every <= 0 && f.Runs == 0) {
+		return false
+	}
+	if lastFull == nil {
+		return true
+	}
+	if f.Runs > 0 && incrRuns+1 >= f.Runs {
+		return true
+	}
+	return f.Every > 0 && !now.Before(lastFull.Add(f.Every))
+}
+
+func (f *FullInterval) String() string {
+	switch {
+	case f == nil:
+		return "never"
+	case f.Runs > 0:
+		return fmt.Sprintf("every %d runs", f.Runs)
+	case f.Every > 0:
+		return "every " + f.Every.String()
+	}
+	return "never"
+}
+
+// ParseDuration extends time.ParseDuration with a "d" (day) unit, which
+// is only allowed as sole unit (i.e. "7d" is valid, "7d12h" is not).
+func ParseDuration(s string) (time.Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.ParseUint(days, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestFullIntervalUnmarshal(t *testing.T) {
+	tests := map[string]FullInterval{
+		"full_every: 7":   {Runs: 7},
+		"full_every: 7d":  {Every: 7 * 24 * time.Hour},
+		"full_every: 36h": {Every: 36 * time.Hour},
+	}
+
+	for input, expected := range tests {
+		var j JobConfig
+		if err := yaml.Unmarshal([]byte(input), &j); err != nil {
+			t.Errorf("%q: unexpected error: %v", input, err)
+			continue
+		}
+		assert.New(t).Equal(&expected, j.FullEvery, input)
+	}
+
+	var j JobConfig
+	assert.New(t).Error(yaml.Unmarshal([]byte("full_every: 7x"), &j))
+}
+
+func TestFullIntervalDue(t *testing.T) {
+	now := time.Date(2018, time.December, 9, 4, 0, 0, 0, time.UTC)
+	lastWeek := now.Add(-7 * 24 * time.Hour)
+	yesterday := now.Add(-24 * time.Hour)
+
+	tests := map[string]struct {
+		f        *FullInterval
+		lastFull *time.Time
+		incrRuns uint
+		expected bool
+	}{
+		"nil":              {nil, nil, 0, false},
+		"zero":             {&FullInterval{}, nil, 0, false},
+		"never full":       {&FullInterval{Runs: 3}, nil, 0, true},
+		"runs.before":      {&FullInterval{Runs: 3}, &yesterday, 1, false},
+		"runs.due":         {&FullInterval{Runs: 3}, &yesterday, 2, true},
+		"every.before":     {&FullInterval{Every: 48 * time.Hour}, &yesterday, 5, false},
+		"every.due":        {&FullInterval{Every: 7 * 24 * time.Hour}, &lastWeek, 0, true},
+		"every.overdue":    {&FullInterval{Every: 48 * time.Hour}, &lastWeek, 0, true},
+		"every.never full": {&FullInterval{Every: 48 * time.Hour}, nil, 0, true},
+	}
+
+	for name := range tests {
+		tc := tests[name]
+		t.Run(name, func(t *testing.T) {
+			actual := tc.f.Due(tc.lastFull, tc.incrRuns, now)
+			assert.New(t).Equal(tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTree writes files (path relative to the root => content) into a
+// temporary config directory, and returns its path.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		name = filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+		require.NoError(t, os.WriteFile(name, []byte(content), 0o644))
+	}
+	return root
+}
+
+// loadTree writes files (see writeTree) and loads them as config tree.
+func loadTree(t *testing.T, files map[string]string) Tree {
+	t.Helper()
+
+	tr := NewTree("")
+	require.NoError(t, tr.SetRoot(writeTree(t, files)))
+	return tr
+}
+
+// findings returns the findings of tr as strings.
+func findings(tr Tree) []string {
+	list := make([]string, 0)
+	for _, f := range tr.Findings() {
+		list = append(list, f.String())
+	}
+	return list
+}
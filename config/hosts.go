@@ -1,13 +1,11 @@
 package config
 
 import (
-	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/pkg/errors"
-	yaml "gopkg.in/yaml.v2"
 )
 
 // HostConfigs maps hostnames to config.
@@ -21,8 +19,12 @@ func pathToHostVariantB(name string) string {
 	return strings.TrimSuffix(path.Base(name), ".yml")
 }
 
-func (h HostConfigs) readGlob(pattern string, matchToHost func(string) string) (err error) {
-	glob, err := filepath.Glob(pattern)
+// failFunc decides whether a config file, which cannot be read, aborts
+// loading the tree (see tree.fail).
+type failFunc func(file, host string, err error) error
+
+func (h HostConfigs) readGlob(root, pattern string, matchToHost func(string) string, fail failFunc) (err error) {
+	glob, err := filepath.Glob(path.Join(root, pattern))
 	if err != nil {
 		err = errors.Wrapf(err, "expanding glob %q failed", pattern)
 		return
@@ -30,9 +32,18 @@ func (h HostConfigs) readGlob(pattern string, matchToHost func(string) string) (
 
 	for _, match := range glob {
 		host := matchToHost(match)
+		file := match
+		if rel, err := filepath.Rel(root, match); err == nil {
+			file = rel
+		}
 		if err = h.readHostConfig(host, match); err != nil {
-			return
+			if err = fail(file, host, err); err != nil {
+				return
+			}
+			continue
 		}
+		h[host].file = file
+		h[host].nameInlineScripts()
 	}
 
 	return
@@ -43,14 +54,8 @@ func (h HostConfigs) readHostConfig(host, file string) (err error) {
 		err = errors.Errorf("duplicate host config for %s found", host)
 		return
 	}
-	var f *os.File
-	if f, err = os.Open(file); err != nil {
-		return
-	}
-	defer f.Close()
-
 	j := JobConfig{}
-	if err = yaml.NewDecoder(f).Decode(&j); err != nil {
+	if j.problems, err = decodeFile(file, &j); err != nil {
 		err = errors.Wrapf(err, "reading %q", file)
 		return
 	}
@@ -60,14 +65,41 @@ func (h HostConfigs) readHostConfig(host, file string) (err error) {
 	return
 }
 
-func (h HostConfigs) readHooks(root string) error {
+func (h HostConfigs) readHooks(root string, fail failFunc) error {
 	for host, job := range h {
-		if err := job.PreScript.readFiles(root, host, "pre.*.sh"); err != nil {
-			return err
+		for _, hook := range job.Hooks() {
+			if err := hook.Script.readFiles(root, host, hook.Pattern); err != nil {
+				if err = fail(job.file, host, err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// readGroups reads the group configs in ROOT_DIR/groups/*.yml.
+func (h HostConfigs) readGroups(root string, fail failFunc) error {
+	glob, err := filepath.Glob(path.Join(root, "groups", "*.yml"))
+	if err != nil {
+		return errors.Wrap(err, "expanding groups glob failed")
+	}
+
+	for _, match := range glob {
+		name := pathToHostVariantB(match)
+		j := JobConfig{file: path.Join("groups", path.Base(match))}
+		if j.problems, err = decodeFile(match, &j); err != nil {
+			if err = fail(j.file, "", errors.Wrapf(err, "reading %q", match)); err != nil {
+				return err
+			}
+			continue
 		}
-		if err := job.PostScript.readFiles(root, host, "post.*.sh"); err != nil {
-			return err
+		if len(j.Groups) > 0 {
+			j.problems = append(j.problems, "groups cannot be nested, ignoring groups")
+			j.Groups = nil
 		}
+		j.nameInlineScripts()
+		h[name] = &j
 	}
 	return nil
 }
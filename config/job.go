@@ -1,14 +1,76 @@
 package config
 
+import "time"
+
 // JobConfig holds config settings for a single backup job.
 type JobConfig struct {
 	host string
+	file string // config file, relative to the tree root
+
+	problems []string // found while decoding, see decodeFile()
+
+	// Groups lists the host groups (see ROOT_DIR/groups/*.yml) this job
+	// inherits from. Only used in host configs.
+	Groups []string `yaml:"groups"`
+
+	// Enabled can be set to false to exclude a host from scheduled
+	// backups, while keeping its config and state. Defaults to true.
+	Enabled *bool `yaml:"enabled"`
+
+	// Opportunistic hosts are not backed up at the scheduled time, but as
+	// soon as they are reachable and their last success is older than
+	// Interval. Defaults to false.
+	Opportunistic *bool `yaml:"opportunistic"`
+
+	// Interval is the desired time between successful backups of an
+	// opportunistic host. Defaults to DefaultInterval.
+	Interval *duration `yaml:"interval"`
 
 	SSH   *SSHConfig   `yaml:"ssh"`
 	RSync *RsyncConfig `yaml:"rsync"`
 
+	// Transport selects how the data is copied. nil: via SSH.
+	Transport *TransportConfig `yaml:"transport"`
+
+	// SourceSnapshot makes rsync copy from a temporary snapshot on the
+	// remote host. nil: copy from the live file system.
+	SourceSnapshot *SourceSnapshotConfig `yaml:"source_snapshot"`
+
+	// WakeOnLAN wakes the host before a backup. nil: disabled.
+	WakeOnLAN *WakeOnLANConfig `yaml:"wake_on_lan"`
+
+	// Streams are remote commands, whose output is written into files
+	// in StreamsDir (e.g. database dumps). Global streams are inherited,
+	// unless a stream with the same name is defined.
+	Streams []StreamConfig `yaml:"streams"`
+
+	// Databases are dumped into StreamsDir, like Streams. Global entries
+	// are inherited, unless an entry with the same name is defined.
+	Databases []DatabaseConfig `yaml:"databases"`
+
 	PreScript  Script `yaml:"pre_script"`  // from yaml file
 	PostScript Script `yaml:"post_script"` // from yaml file
+
+	// FinallyScript is executed on the remote host after the pre-scripts
+	// have been started, regardless of the outcome.
+	FinallyScript Script `yaml:"finally_script"`
+
+	// Local scripts are executed on the backup server, before any remote
+	// pre-script and after any remote post-script.
+	LocalPreScript  Script `yaml:"local_pre_script"`
+	LocalPostScript Script `yaml:"local_post_script"`
+
+	// ScriptTimeout limits the run time of each hook script. Defaults to
+	// DefaultScriptTimeout.
+	ScriptTimeout *duration `yaml:"script_timeout"`
+
+	// Secrets are exported as environment variables to the hook scripts.
+	// Values may contain ${env:NAME} and ${file:/path} references, which
+	// are resolved at job time (see Resolver).
+	Secrets map[string]string `yaml:"secrets"`
+
+	FullEvery *FullInterval    `yaml:"full_every"` // nil: never run with --checksum
+	Bandwidth *BandwidthConfig `yaml:"bandwidth"`  // nil: unlimited
 }
 
 // SSHConfig holds connection parameters.
@@ -23,7 +85,207 @@ func (j *JobConfig) Host() string {
 	return j.host
 }
 
+// File returns the path to the host's config file, relative to the
+// config tree root.
+func (j *JobConfig) File() string {
+	return j.file
+}
+
+// Hook describes a hook script of a job.
+type Hook struct {
+	Key     string  // YAML key, e.g. "pre_script"
+	Pattern string  // file name pattern in the host directory, e.g. "pre.*.sh"
+	Script  *Script // points into the JobConfig
+}
+
+// Hooks returns the hook scripts of j, in order of execution.
+func (j *JobConfig) Hooks() []Hook {
+	return []Hook{
+		{"local_pre_script", "local_pre.*.sh", &j.LocalPreScript},
+		{"pre_script", "pre.*.sh", &j.PreScript},
+		{"post_script", "post.*.sh", &j.PostScript},
+		{"finally_script", "finally.*.sh", &j.FinallyScript},
+		{"local_post_script", "local_post.*.sh", &j.LocalPostScript},
+	}
+}
+
+// nameInlineScripts names the inline script units after the job's config
+// file and YAML key.
+func (j *JobConfig) nameInlineScripts() {
+	for _, h := range j.Hooks() {
+		h.Script.setSource(j.file, h.Key)
+	}
+}
+
+// DefaultScriptTimeout is the default value for JobConfig.ScriptTimeout.
+const DefaultScriptTimeout = 15 * time.Minute
+
+// HookTimeout returns the maximum run time of a single hook script.
+func (j *JobConfig) HookTimeout() time.Duration {
+	if j.ScriptTimeout == nil || *j.ScriptTimeout <= 0 {
+		return DefaultScriptTimeout
+	}
+	return time.Duration(*j.ScriptTimeout)
+}
+
+// IsEnabled reports whether scheduled backups are enabled for this job.
+func (j *JobConfig) IsEnabled() bool {
+	return j.Enabled == nil || *j.Enabled
+}
+
+// clone returns a deep copy of j.
+func (j *JobConfig) clone() *JobConfig { //nolint:funlen
+	c := *j
+	c.problems = nil
+	c.Groups = cloneStrings(j.Groups)
+
+	if j.Enabled != nil {
+		dup := *j.Enabled
+		c.Enabled = &dup
+	}
+	if j.Opportunistic != nil {
+		dup := *j.Opportunistic
+		c.Opportunistic = &dup
+	}
+	if j.Interval != nil {
+		dup := *j.Interval
+		c.Interval = &dup
+	}
+
+	if j.SSH != nil {
+		ssh := *j.SSH
+		if j.SSH.Timeout != nil {
+			dup := *j.SSH.Timeout
+			ssh.Timeout = &dup
+		}
+		c.SSH = &ssh
+	}
+	if j.RSync != nil {
+		r := *j.RSync
+		r.Included = cloneStrings(j.RSync.Included)
+		r.Excluded = cloneStrings(j.RSync.Excluded)
+		r.Arguments = cloneStrings(j.RSync.Arguments)
+		if j.RSync.Filter != nil {
+			r.Filter = append([]FilterRule(nil), j.RSync.Filter...)
+		}
+		if j.RSync.Resume != nil {
+			dup := *j.RSync.Resume
+			r.Resume = &dup
+		}
+		c.RSync = &r
+	}
+
+	if j.Transport != nil {
+		dup := *j.Transport
+		dup.Datasets = append([]string(nil), j.Transport.Datasets...)
+		c.Transport = &dup
+	}
+	if j.SourceSnapshot != nil {
+		c.SourceSnapshot = j.SourceSnapshot.clone()
+	}
+	if j.WakeOnLAN != nil {
+		c.WakeOnLAN = j.WakeOnLAN.clone()
+	}
+
+	c.Streams = cloneStreams(j.Streams)
+	c.Databases = cloneDatabases(j.Databases)
+
+	for _, h := range c.Hooks() {
+		*h.Script = h.Script.clone()
+	}
+	if j.ScriptTimeout != nil {
+		dup := *j.ScriptTimeout
+		c.ScriptTimeout = &dup
+	}
+
+	if j.Secrets != nil {
+		c.Secrets = make(map[string]string, len(j.Secrets))
+		for name, val := range j.Secrets {
+			c.Secrets[name] = val
+		}
+	}
+
+	if j.FullEvery != nil {
+		dup := *j.FullEvery
+		c.FullEvery = &dup
+	}
+	if j.Bandwidth != nil {
+		bw := *j.Bandwidth
+		if j.Bandwidth.Windows != nil {
+			bw.Windows = append([]BandwidthWindow(nil), j.Bandwidth.Windows...)
+		}
+		c.Bandwidth = &bw
+	}
+	return &c
+}
+
+func cloneStreams(streams []StreamConfig) []StreamConfig {
+	if streams == nil {
+		return nil
+	}
+	c := make([]StreamConfig, len(streams))
+	for i, s := range streams {
+		c[i] = s
+		if s.Timeout != nil {
+			dup := *s.Timeout
+			c[i].Timeout = &dup
+		}
+	}
+	return c
+}
+
+func cloneDatabases(dbs []DatabaseConfig) []DatabaseConfig {
+	if dbs == nil {
+		return nil
+	}
+	c := make([]DatabaseConfig, len(dbs))
+	for i, d := range dbs {
+		c[i] = d
+		c[i].Databases = cloneStrings(d.Databases)
+	}
+	return c
+}
+
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
+// inherit merges the given layers into j. The layers are ordered by
+// increasing precedence (i.e. globals first, then groups), and each layer
+// is merged into the next one with the same rules as mergeGlobals, so
+// override_global_* in a layer inhibits inheritance from all layers
+// before it.
+func (j *JobConfig) inherit(layers ...*JobConfig) {
+	if len(layers) == 0 {
+		return
+	}
+
+	base := layers[0].clone()
+	for _, layer := range layers[1:] {
+		next := layer.clone()
+		next.mergeGlobals(base)
+		base = next
+	}
+	j.mergeGlobals(base)
+}
+
 func (j *JobConfig) mergeGlobals(globals *JobConfig) {
+	if j.Enabled == nil && globals.Enabled != nil {
+		dup := *globals.Enabled
+		j.Enabled = &dup
+	}
+	if j.Opportunistic == nil && globals.Opportunistic != nil {
+		dup := *globals.Opportunistic
+		j.Opportunistic = &dup
+	}
+	if j.Interval == nil && globals.Interval != nil {
+		dup := *globals.Interval
+		j.Interval = &dup
+	}
+
 	//nolint:nestif
 	if globals.SSH != nil {
 		if j.SSH == nil {
@@ -55,17 +317,83 @@ func (j *JobConfig) mergeGlobals(globals *JobConfig) {
 			if !j.RSync.OverrideGlobalExclude {
 				j.RSync.Excluded = append(j.RSync.Excluded, globals.RSync.Excluded...)
 			}
+			if !j.RSync.OverrideGlobalFilter {
+				j.RSync.Filter = append(j.RSync.Filter, globals.RSync.Filter...)
+			}
 			if !j.RSync.OverrideGlobalArguments {
 				j.RSync.Arguments = append(j.RSync.Arguments, globals.RSync.Arguments...)
 			}
+			if j.RSync.Resume == nil && globals.RSync.Resume != nil {
+				dup := *globals.RSync.Resume
+				j.RSync.Resume = &dup
+			}
+		}
+	}
+
+	if j.Transport == nil && globals.Transport != nil {
+		dup := *globals.Transport
+		dup.Datasets = append([]string(nil), globals.Transport.Datasets...)
+		j.Transport = &dup
+	}
+
+	if j.SourceSnapshot == nil && globals.SourceSnapshot != nil {
+		j.SourceSnapshot = globals.SourceSnapshot.clone()
+	}
+	if j.WakeOnLAN == nil && globals.WakeOnLAN != nil {
+		j.WakeOnLAN = globals.WakeOnLAN.clone()
+	}
+
+	for _, gs := range cloneStreams(globals.Streams) {
+		defined := false
+		for _, s := range j.Streams {
+			defined = defined || s.Name == gs.Name
+		}
+		if !defined {
+			j.Streams = append(j.Streams, gs)
+		}
+	}
+
+	for _, gd := range cloneDatabases(globals.Databases) {
+		defined := false
+		for _, d := range j.Databases {
+			defined = defined || d.DirName() == gd.DirName()
+		}
+		if !defined {
+			j.Databases = append(j.Databases, gd)
 		}
 	}
 
-	// globals.PreScript
-	j.PreScript.inline = append(globals.PreScript.inline, j.PreScript.inline...)
-	j.PreScript.scripts = append(globals.PreScript.scripts, j.PreScript.scripts...)
+	if j.ScriptTimeout == nil && globals.ScriptTimeout != nil {
+		dup := *globals.ScriptTimeout
+		j.ScriptTimeout = &dup
+	}
 
-	// globals.PostScript
-	j.PostScript.inline = append(globals.PostScript.inline, j.PostScript.inline...)
-	j.PostScript.scripts = append(globals.PostScript.scripts, j.PostScript.scripts...)
+	if j.FullEvery == nil && globals.FullEvery != nil {
+		dup := *globals.FullEvery
+		j.FullEvery = &dup
+	}
+
+	if globals.Bandwidth != nil {
+		if j.Bandwidth == nil {
+			dup := *globals.Bandwidth
+			j.Bandwidth = &dup
+		} else if j.Bandwidth.Group == "" {
+			j.Bandwidth.Group = globals.Bandwidth.Group
+		}
+	}
+
+	for name, val := range globals.Secrets {
+		if _, ok := j.Secrets[name]; !ok {
+			if j.Secrets == nil {
+				j.Secrets = make(map[string]string)
+			}
+			j.Secrets[name] = val
+		}
+	}
+
+	// global scripts come first
+	globalHooks := globals.Hooks()
+	for i, h := range j.Hooks() {
+		h.Script.inherit(globalHooks[i].Script)
+	}
 }
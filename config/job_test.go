@@ -158,11 +158,20 @@ func TestMergeConfigRSync(t *testing.T) { //nolint:funlen
 	}
 }
 
+// units returns a script unit for each line.
+func units(lines ...string) []ScriptUnit {
+	u := make([]ScriptUnit, len(lines))
+	for i, line := range lines {
+		u[i] = ScriptUnit{Lines: []string{line}}
+	}
+	return u
+}
+
 func TestMergeConfigScripts(t *testing.T) {
 	defaultConf := func() Script {
 		return Script{
-			inline:  []string{"echo global inline"},
-			scripts: []string{"echo global scripts"},
+			inline:  units("echo global inline"),
+			scripts: units("echo global scripts"),
 		}
 	}
 
@@ -175,17 +184,17 @@ func TestMergeConfigScripts(t *testing.T) {
 			defaultConf(),
 		},
 		"inline": {
-			Script{inline: []string{"echo local inline"}},
+			Script{inline: units("echo local inline")},
 			Script{
-				inline:  []string{"echo global inline", "echo local inline"},
-				scripts: []string{"echo global scripts"},
+				inline:  units("echo global inline", "echo local inline"),
+				scripts: units("echo global scripts"),
 			},
 		},
 		"scripts": {
-			Script{scripts: []string{"echo local scripts"}},
+			Script{scripts: units("echo local scripts")},
 			Script{
-				inline:  []string{"echo global inline"},
-				scripts: []string{"echo global scripts", "echo local scripts"},
+				inline:  units("echo global inline"),
+				scripts: units("echo global scripts", "echo local scripts"),
 			},
 		},
 	}
@@ -210,3 +219,190 @@ func TestMergeConfigScripts(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeConfigRSyncFilter(t *testing.T) {
+	globals := &JobConfig{RSync: &RsyncConfig{Filter: []FilterRule{"- *.tmp"}}}
+
+	actual := &JobConfig{RSync: &RsyncConfig{Filter: []FilterRule{"P /srv"}}}
+	actual.mergeGlobals(globals)
+	assert.New(t).Equal([]FilterRule{"P /srv", "- *.tmp"}, actual.RSync.Filter)
+
+	actual = &JobConfig{RSync: &RsyncConfig{Filter: []FilterRule{"P /srv"}, OverrideGlobalFilter: true}}
+	actual.mergeGlobals(globals)
+	assert.New(t).Equal([]FilterRule{"P /srv"}, actual.RSync.Filter)
+}
+
+func TestMergeConfigEnabled(t *testing.T) {
+	no, yes := false, true
+	assert := assert.New(t)
+
+	actual := &JobConfig{}
+	actual.mergeGlobals(&JobConfig{})
+	assert.True(actual.IsEnabled())
+
+	actual = &JobConfig{}
+	actual.mergeGlobals(&JobConfig{Enabled: &no})
+	assert.False(actual.IsEnabled())
+
+	actual = &JobConfig{Enabled: &yes}
+	actual.mergeGlobals(&JobConfig{Enabled: &no})
+	assert.True(actual.IsEnabled())
+}
+
+func TestRsyncFilter(t *testing.T) {
+	r := &RsyncConfig{
+		Included: []string{"/home/craig", "/var/log", "/etc"},
+		Excluded: []string{"*.log"},
+		Filter:   []FilterRule{"dir-merge /.rsync-filter"},
+	}
+
+	assert.New(t).Equal([]string{
+		"--filter=dir-merge /.rsync-filter",
+		"--include=/home",
+		"--include=/home/craig",
+		"--include=/var",
+		"--include=/var/log",
+		"--include=/etc",
+		"--exclude=/*",
+		"--exclude=/home/*",
+		"--exclude=/var/*",
+		"--exclude=*.log",
+	}, r.filter())
+
+	assert.New(t).Equal([]string{
+		"dir-merge /.rsync-filter",
+		"+ /home",
+		"+ /home/craig",
+		"+ /var",
+		"+ /var/log",
+		"+ /etc",
+		"- /*",
+		"- /home/*",
+		"- /var/*",
+		"- *.log",
+	}, r.FilterRules())
+
+	root := &RsyncConfig{Included: []string{"/"}}
+	assert.New(t).Equal([]string{"+ /"}, root.FilterRules())
+}
+
+func TestRsyncBuildArgVectorFilterFile(t *testing.T) {
+	r := &RsyncConfig{Included: []string{"/etc"}}
+	args := r.BuildArgVector(RsyncSource{Shell: "ssh", Base: "root@host:"}, "/zackup/host", ArgOptions{FilterFile: "/zackup/.zackup/filter/host.rules"})
+
+	assert.New(t).Equal("--filter=merge /zackup/.zackup/filter/host.rules", args[0])
+	assert.New(t).NotContains(args, "--include=/etc")
+	assert.New(t).Equal([]string{"root@host:/", "/zackup/host/"}, args[len(args)-2:])
+}
+
+func TestRsyncBuildArgVectorDryRun(t *testing.T) {
+	r := &RsyncConfig{}
+	args := r.BuildArgVector(RsyncSource{Shell: "ssh", Base: "root@host:"}, "/zackup/host", ArgOptions{DryRun: true})
+
+	assert.New(t).Equal([]string{"--dry-run", "--stats", "--out-format=%i %l %n", "root@host:/", "/zackup/host/"}, args[len(args)-5:])
+	assert.New(t).NotContains(r.BuildArgVector(RsyncSource{}, "/zackup/host", ArgOptions{}), "--dry-run")
+}
+
+func TestInheritLayers(t *testing.T) {
+	globals := &JobConfig{
+		SSH:       &SSHConfig{User: "root", Port: 22},
+		RSync:     &RsyncConfig{Included: []string{"/etc"}, Arguments: []string{"--numeric-ids"}},
+		PreScript: Script{inline: units("echo global")},
+	}
+	web := &JobConfig{
+		SSH:       &SSHConfig{Port: 2222},
+		RSync:     &RsyncConfig{Included: []string{"/var/www"}},
+		PreScript: Script{inline: units("echo web")},
+	}
+	debian := &JobConfig{
+		RSync: &RsyncConfig{Arguments: []string{"--hard-links"}, OverrideGlobalArguments: true},
+	}
+
+	actual := &JobConfig{
+		RSync:     &RsyncConfig{Included: []string{"/srv"}},
+		PreScript: Script{inline: units("echo host")},
+	}
+	actual.inherit(globals, web, debian)
+
+	assert := assert.New(t)
+	assert.Equal(&SSHConfig{User: "root", Port: 2222}, actual.SSH)
+	assert.Equal([]string{"/srv", "/var/www", "/etc"}, actual.RSync.Included)
+	assert.Equal([]string{"--hard-links"}, actual.RSync.Arguments)
+	assert.Equal([]string{"echo global", "echo web", "echo host"}, actual.PreScript.Lines())
+
+	// layers are left untouched
+	assert.Equal([]string{"/var/www"}, web.RSync.Included)
+	assert.Equal([]string{"--numeric-ids"}, globals.RSync.Arguments)
+}
+
+func TestJobConfigClone(t *testing.T) {
+	resume := true
+	orig := &JobConfig{
+		RSync:          &RsyncConfig{Included: []string{"/etc"}, Resume: &resume},
+		SourceSnapshot: &SourceSnapshotConfig{Type: SnapshotZFS, Source: "rpool/ROOT/debian"},
+		Bandwidth:      &BandwidthConfig{Windows: []BandwidthWindow{{Limit: 100}}},
+		Secrets:        map[string]string{"TOKEN": "secret"},
+		PreScript:      Script{inline: units("echo pre")},
+	}
+
+	c := orig.clone()
+	c.RSync.Included[0] = "/srv"
+	*c.RSync.Resume = false
+	c.SourceSnapshot.Type = SnapshotLVM
+	c.SourceSnapshot.Source = "vg0/root"
+	c.Bandwidth.Windows[0].Limit = 200
+	c.Secrets["TOKEN"] = "changed"
+	c.PreScript.inline[0].Lines[0] = "echo changed"
+
+	assert := assert.New(t)
+	assert.Equal([]string{"/etc"}, orig.RSync.Included)
+	assert.True(*orig.RSync.Resume)
+	assert.Equal(&SourceSnapshotConfig{Type: SnapshotZFS, Source: "rpool/ROOT/debian"}, orig.SourceSnapshot)
+	assert.Equal(Rate(100), orig.Bandwidth.Windows[0].Limit)
+	assert.Equal("secret", orig.Secrets["TOKEN"])
+	assert.Equal([]string{"echo pre"}, orig.PreScript.Lines())
+}
+
+func TestTreeGroups(t *testing.T) {
+	tr := NewTree("")
+	if err := tr.SetRoot("../testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+	assert.Equal([]string{"web"}, tr.Groups())
+	assert.Equal([]string{"example.com"}, tr.GroupHosts("web"))
+	assert.Empty(tr.GroupHosts("unknown"))
+
+	job := tr.Host("example.com")
+	assert.Equal([]string{"/var/www", "/etc/nginx"}, job.RSync.Included)
+	assert.Equal([]string{"echo web group pre", "echo example.com pre", "date +%n"}, job.PreScript.Lines())
+
+	out, err := tr.Describe("example.com")
+	assert.NoError(err)
+	assert.Contains(string(out), "    - /etc/nginx # groups/web.yml\n")
+	assert.Contains(string(out), "pre_script: | # groups/web.yml, hosts/example.com.yml\n")
+}
+
+func TestTreeLocalHooks(t *testing.T) {
+	tr := loadTree(t, map[string]string{
+		"config.yml":                   "---\n",
+		"globals.yml":                  "local_pre_script: echo global\n",
+		"hosts/example.com/config.yml": "local_post_script: echo inline\n",
+		"example.com/local_pre.1.sh":   "wakeonlan 00:11:22:33:44:55\n",
+		"example.com/local_post.1.sh":  "echo $ZACKUP_RESULT\n",
+		"example.com/pre.1.sh":         "echo remote\n",
+	})
+
+	job := tr.Host("example.com")
+
+	assert := assert.New(t)
+	assert.Equal([]string{"echo global", "wakeonlan 00:11:22:33:44:55"}, job.LocalPreScript.Lines())
+	assert.Equal([]string{"echo remote"}, job.PreScript.Lines())
+	assert.Empty(job.PostScript.Lines())
+	assert.Equal([]string{"echo inline", "echo $ZACKUP_RESULT"}, job.LocalPostScript.Lines())
+
+	out, err := tr.Describe("example.com")
+	assert.NoError(err)
+	assert.Contains(string(out), "local_pre_script: | # globals.yml, example.com/local_pre.*.sh\n")
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultInterval is the default value for JobConfig.Interval.
+const DefaultInterval = 24 * time.Hour
+
+// IsOpportunistic reports whether the host is backed up whenever it is
+// reachable, instead of at the scheduled time.
+func (j *JobConfig) IsOpportunistic() bool {
+	return j.Opportunistic != nil && *j.Opportunistic
+}
+
+// DesiredInterval returns the desired time between successful backups
+// of an opportunistic host.
+func (j *JobConfig) DesiredInterval() time.Duration {
+	if j.Interval == nil || *j.Interval <= 0 {
+		return DefaultInterval
+	}
+	return time.Duration(*j.Interval)
+}
+
+// ProbeAddress returns the TCP address ("host:port"), which is probed to
+// check whether an opportunistic host is reachable: the rsync daemon for
+// the rsyncd transport, and the SSH server otherwise.
+func (j *JobConfig) ProbeAddress() string {
+	port := uint16(22)
+	if j.TransportType() == TransportRsyncd {
+		port = 873
+		if j.Transport.Port != 0 {
+			port = j.Transport.Port
+		}
+	} else if j.SSH != nil && j.SSH.Port != 0 {
+		port = j.SSH.Port
+	}
+	return net.JoinHostPort(j.Host(), strconv.Itoa(int(port)))
+}
+
+// checkOpportunistic returns problems with the opportunistic settings of
+// the merged config j.
+func (j *JobConfig) checkOpportunistic() (problems []string) {
+	if j.Interval != nil && *j.Interval <= 0 {
+		problems = append(problems, "interval must be positive")
+	}
+	if !j.IsOpportunistic() {
+		return problems
+	}
+	if t := j.TransportType(); t == TransportLocal || t == TransportPush {
+		problems = append(problems, "opportunistic is not supported with transport "+t)
+	}
+	return problems
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpportunistic(t *testing.T) {
+	tr := loadTree(t, map[string]string{
+		"config.yml":           "parallel: 1\nroot_dataset: zpool/zackup\nmount_base: /zpool/zackup\n",
+		"globals.yml":          "interval: 12h\n",
+		"hosts/laptop.com.yml": "opportunistic: true\nssh: {port: 2222}\n",
+		"hosts/nas.com.yml":    "opportunistic: true\ninterval: 7d\ntransport: {type: rsyncd, module: backup}\n",
+		"hosts/server.com.yml": "---\n",
+		"hosts/local.com.yml":  "opportunistic: true\ntransport: {type: local, path: /srv}\n",
+	})
+
+	assert := assert.New(t)
+	laptop, nas, server := tr.Host("laptop.com"), tr.Host("nas.com"), tr.Host("server.com")
+	assert.True(laptop.IsOpportunistic())
+	assert.Equal(12*time.Hour, laptop.DesiredInterval())
+	assert.Equal("laptop.com:2222", laptop.ProbeAddress())
+	assert.Equal(7*24*time.Hour, nas.DesiredInterval())
+	assert.Equal("nas.com:873", nas.ProbeAddress())
+	assert.False(server.IsOpportunistic())
+	assert.Equal("server.com:22", server.ProbeAddress())
+
+	assert.Equal([]string{
+		"hosts/local.com.yml (host local.com): opportunistic is not supported with transport local",
+	}, findings(tr))
+}
@@ -1,36 +1,83 @@
 package config
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 )
 
 // RsyncConfig holds config value for the rsync binary.
 type RsyncConfig struct {
-	Included  []string `yaml:"include"`
-	Excluded  []string `yaml:"exclude"`
-	Arguments []string `yaml:"args"`
+	Included  []string     `yaml:"include"`
+	Excluded  []string     `yaml:"exclude"`
+	Filter    []FilterRule `yaml:"filter"`
+	Arguments []string     `yaml:"args"`
 
 	// OverrideGlobalInclude (and other OverrideGlobal* fields) inhibits
 	// the inheritance of global Included values (or other fields) when
 	// set to true.
 	OverrideGlobalInclude   bool `yaml:"override_global_include"`
 	OverrideGlobalExclude   bool `yaml:"override_global_exclude"` // see OverrideGlobalInclude
+	OverrideGlobalFilter    bool `yaml:"override_global_filter"`  // see OverrideGlobalInclude
 	OverrideGlobalArguments bool `yaml:"override_global_args"`    // see OverrideGlobalInclude
+
+	// Resume enables keeping partially transferred files between runs.
+	// Inherited from the global config, if nil.
+	Resume *bool `yaml:"resume"`
 }
 
-// BuildArgVector creates an ARGV for rsync.
-func (r *RsyncConfig) BuildArgVector(ssh, src, dst string) []string {
-	if !strings.HasSuffix(src, "/") {
-		src += "/"
-	}
+// ArgOptions holds per-run values for BuildArgVector.
+type ArgOptions struct {
+	// Full makes rsync compare file checksums instead of size and
+	// modification time (--checksum).
+	Full bool
+
+	// PartialDir is the directory in which rsync keeps partially
+	// transferred files (--partial-dir). Ignored, if empty.
+	PartialDir string
+
+	// BandwidthLimit limits the transfer rate (--bwlimit). Zero means
+	// unlimited.
+	BandwidthLimit Rate
+
+	// FilterFile names a file containing the output of FilterRules().
+	// If set, it is passed as --filter="merge FilterFile", otherwise
+	// the rules are expanded into --include/--exclude/--filter arguments.
+	FilterFile string
+
+	// DryRun makes rsync only report what would be transferred: the
+	// changes are itemized with DryRunFormat, followed by --stats.
+	DryRun bool
+}
+
+// DryRunFormat is the --out-format of dry runs: itemized changes, file
+// size and name.
+const DryRunFormat = "%i %l %n"
+
+// ResumeEnabled reports whether partially transferred files should be
+// kept between runs.
+func (r *RsyncConfig) ResumeEnabled() bool {
+	return r != nil && r.Resume != nil && *r.Resume
+}
+
+// BuildArgVector creates an ARGV for rsync. Include and exclude paths
+// are relative to the source path.
+func (r *RsyncConfig) BuildArgVector(src RsyncSource, dst string, opts ArgOptions) []string {
 	if !strings.HasSuffix(dst, "/") {
 		dst += "/"
 	}
 
-	args := r.filter()               // whatever is configured for this host
-	args = append(args, "-e", ssh)   // -e 'ssh -S controlPath -p port -x'
-	args = append(args, r.args()...) // --include ... --exclude ...
+	var args []string
+	if opts.FilterFile != "" {
+		args = append(args, "--filter=merge "+opts.FilterFile)
+	} else {
+		args = r.filter() // --include ... --exclude ...
+	}
+	if src.Shell != "" {
+		args = append(args, "-e", src.Shell) // -e 'ssh -S controlPath -p port -x'
+	}
+	args = append(args, src.Args...) // transport specific, e.g. --password-file
+	args = append(args, r.args()...) // whatever is configured for this host
 
 	args = append(args,
 		// delete from dest (also if excluded), but at the end
@@ -38,19 +85,67 @@ func (r *RsyncConfig) BuildArgVector(ssh, src, dst string) []string {
 		// the following tunes logging capabilities
 		"--itemize-changes",
 	)
+	if opts.Full {
+		args = append(args, "--checksum")
+	}
+	if opts.PartialDir != "" {
+		args = append(args, "--partial-dir="+opts.PartialDir)
+	}
+	if opts.BandwidthLimit > 0 {
+		args = append(args, fmt.Sprintf("--bwlimit=%d", opts.BandwidthLimit.KiB()))
+	}
+	if opts.DryRun {
+		args = append(args, "--dry-run", "--stats", "--out-format="+DryRunFormat)
+	}
 
-	args = append(args, src, dst) // user@host:/ /zackup/host/
+	args = append(args, src.String(), dst) // user@host:/source/path/ /zackup/host/
 	return args
 }
 
-// filter builds the filter argument list (--include/--exclude) for rsync.
+// filter builds the filter argument list (--filter/--include/--exclude)
+// for rsync. See expand() for details.
+func (r *RsyncConfig) filter() (list []string) {
+	inc, exc := r.expand()
+
+	for _, rule := range r.Filter {
+		list = append(list, "--filter="+string(rule))
+	}
+	for _, f := range inc {
+		list = append(list, "--include="+f)
+	}
+	for _, f := range exc {
+		list = append(list, "--exclude="+f)
+	}
+	return list
+}
+
+// FilterRules returns the content of an rsync filter merge file. It
+// contains the same rules as filter() would generate: raw filter rules
+// come first, followed by the expanded include and exclude paths.
+func (r *RsyncConfig) FilterRules() []string {
+	inc, exc := r.expand()
+
+	list := make([]string, 0, len(r.Filter)+len(inc)+len(exc))
+	for _, rule := range r.Filter {
+		list = append(list, string(rule))
+	}
+	for _, f := range inc {
+		list = append(list, "+ "+f)
+	}
+	for _, f := range exc {
+		list = append(list, "- "+f)
+	}
+	return list
+}
+
+// expand builds the include and exclude pattern lists for rsync.
 // This is modelled after BackupPC:
 // https://github.com/backuppc/backuppc/blob/master/lib/BackupPC/Xfer/Rsync.pm#L234
 //
 // Most of the complexity is based in the fact that we do an rsync from
 // `host:/`, i.e. we start to copy from the root directory of the remote
 // host.
-func (r *RsyncConfig) filter() (list []string) { //nolint:funlen
+func (r *RsyncConfig) expand() (inc, exc []string) {
 	// Original comments are marked as quote ("// >").
 	//
 	// > If the user wants to just include /home/craig, then we need to do create
@@ -69,7 +164,6 @@ func (r *RsyncConfig) filter() (list []string) { //nolint:funlen
 	// > To make this easier we do all the includes first and all of the excludes at
 	// > the end (hopefully they commute).
 
-	var inc, exc []string
 	incDone := make(map[string]struct{})
 	excDone := make(map[string]struct{})
 
@@ -106,16 +200,8 @@ func (r *RsyncConfig) filter() (list []string) { //nolint:funlen
 		}
 	}
 
-	for _, f := range inc {
-		list = append(list, "--include="+f)
-	}
-	for _, f := range exc {
-		list = append(list, "--exclude="+f)
-	}
-	for _, f := range r.Excluded {
-		// > just append additional exclude lists onto the end
-		list = append(list, "--exclude="+f)
-	}
+	// > just append additional exclude lists onto the end
+	exc = append(exc, r.Excluded...)
 
-	return list
+	return inc, exc
 }
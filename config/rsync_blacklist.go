@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/tidwall/match"
@@ -26,6 +27,7 @@ var blacklistArgs = []blacklistArg{
 	{long: `--itemize-changes`, short: `-i`},   // defines a machine readable output
 	{long: `--out-format`, opt: true},          // would override --itemize-changes
 	{long: `--partial`},                        // "keep partially transferred files". nope.
+	{long: `--partial-dir`, opt: true},         // is constructed separately (see RsyncConfig.Resume)
 	{long: `--progress`, short: "-P"},          // produces ANSI escape sequences for a human-readable progress meter
 	{long: `--daemon`},                         // VERY bad idea to deamonize the rsync instance
 }
@@ -76,7 +78,15 @@ func (bla *blacklistArg) n() int {
 // args removes blacklisted values from r.Arguments, to prevent you
 // from shooting yourself in the foot.
 func (r *RsyncConfig) args() []string {
-	f := make([]string, 0, len(r.Arguments))
+	kept, _ := r.splitArgs()
+	return kept
+}
+
+// splitArgs separates r.Arguments into allowed and blacklisted values.
+// A blacklisted flag which swallows the next token is reported together
+// with that token.
+func (r *RsyncConfig) splitArgs() (kept, dropped []string) {
+	kept = make([]string, 0, len(r.Arguments))
 
 	for i := 0; i < len(r.Arguments); i++ {
 		blacklisted := false
@@ -84,14 +94,93 @@ func (r *RsyncConfig) args() []string {
 
 		for _, flag := range blacklistArgs {
 			if matches, n := flag.Matches(arg); matches {
+				if n > 0 && i+n < len(r.Arguments) {
+					arg = strings.Join(r.Arguments[i:i+n+1], " ")
+				}
 				i += n
 				blacklisted = true
 				break
 			}
 		}
-		if !blacklisted {
-			f = append(f, arg)
+		if blacklisted {
+			dropped = append(dropped, arg)
+		} else {
+			kept = append(kept, arg)
+		}
+	}
+	return kept, dropped
+}
+
+// checkArgs reports blacklisted values in r.Arguments (which args()
+// silently drops), and values which look like a mistake, e.g. long
+// options with a single dash ("-bwlimit" instead of "--bwlimit").
+func (r *RsyncConfig) checkArgs() (problems []string) {
+	if r == nil {
+		return nil
+	}
+
+	kept, dropped := r.splitArgs()
+	for _, arg := range dropped {
+		problems = append(problems, fmt.Sprintf("rsync argument %q is not allowed and will be ignored", arg))
+	}
+	for _, arg := range kept {
+		if suspiciousArg(arg) {
+			problems = append(problems, fmt.Sprintf("rsync argument %q looks like a long option with a single dash", arg))
 		}
 	}
-	return f
+	return problems
+}
+
+// suspiciousArg detects long options written with a single dash. rsync
+// would interpret "-bwlimit=100" as a sequence of short options. Only
+// known option names are reported, so that bundles of short options
+// (e.g. "-rlptgoz") are left alone.
+func suspiciousArg(arg string) bool {
+	if len(arg) < 3 || arg[0] != '-' || arg[1] == '-' {
+		return false
+	}
+
+	name, _, _ := strings.Cut(arg[1:], "=")
+	for _, long := range rsyncLongOptions {
+		if name == long {
+			return true
+		}
+	}
+	return false
+}
+
+// rsyncLongOptions lists the long options of rsync (3.2), without the
+// leading dashes.
+var rsyncLongOptions = []string{
+	"acls", "address", "append", "append-verify", "archive", "atimes",
+	"backup", "backup-dir", "block-size", "blocking-io", "bwlimit",
+	"cc", "checksum", "checksum-choice", "checksum-seed", "chmod",
+	"chown", "compare-dest", "compress", "compress-choice",
+	"compress-level", "copy-as", "copy-dest", "copy-dirlinks",
+	"copy-links", "copy-unsafe-links", "crtimes", "cvs-exclude",
+	"daemon", "debug", "del", "delay-updates", "delete",
+	"delete-after", "delete-before", "delete-delay", "delete-during",
+	"delete-excluded", "delete-missing-args", "devices", "dirs",
+	"dry-run", "early-input", "exclude", "exclude-from", "executability",
+	"existing", "fake-super", "files-from", "filter", "force",
+	"from0", "fsync", "fuzzy", "group", "groupmap", "hard-links",
+	"human-readable", "iconv", "ignore-errors", "ignore-existing",
+	"ignore-missing-args", "ignore-times", "include", "include-from",
+	"info", "inplace", "ipv4", "ipv6", "itemize-changes",
+	"keep-dirlinks", "link-dest", "links", "list-only", "log-file",
+	"log-file-format", "max-alloc", "max-delete", "max-size",
+	"min-size", "mkpath", "modify-window", "munge-links",
+	"no-implied-dirs", "no-motd", "numeric-ids", "old-args",
+	"old-compress", "omit-dir-times", "omit-link-times",
+	"one-file-system", "only-write-batch", "open-noatime",
+	"out-format", "outbuf", "owner", "partial", "partial-dir",
+	"password-file", "perms", "port", "preallocate", "progress",
+	"protect-args", "protocol", "prune-empty-dirs", "quiet",
+	"read-batch", "recursive", "relative", "remote-option",
+	"remove-source-files", "rsh", "rsync-path", "safe-links",
+	"secluded-args", "size-only", "skip-compress", "sockopts",
+	"sparse", "specials", "stats", "stderr", "stop-after", "stop-at",
+	"suffix", "super", "temp-dir", "timeout", "times", "trust-sender",
+	"update", "usermap", "verbose", "whole-file", "write-batch",
+	"write-devices", "xattrs",
 }
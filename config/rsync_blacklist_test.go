@@ -73,6 +73,7 @@ func TestRsyncArgs(t *testing.T) {
 			"--include=b",             // --include=* does NOT swallow next token
 			"--rsh=ssh -oThird=turd",  // similar to --include=*
 			"--rsh", "ssh -oOther=no", // similar to -e
+			"--partial-dir=.partial", // constructed from RsyncConfig.Resume
 			"--numeric-ids",
 		},
 	}
@@ -91,3 +92,34 @@ func TestRsyncArgs(t *testing.T) {
 		t.Errorf("actual=%q, expected=%q\n", actual, expected)
 	}
 }
+
+func TestRsyncCheckArgs(t *testing.T) {
+	c := &RsyncConfig{
+		Arguments: []string{
+			"--perms",
+			"-e", "ssh -oSomething=yes", // reported with swallowed token
+			"--partial",
+			"-bwlimit=100", // single dash, with value
+			"-numeric-ids", // single dash, with dash
+			"-delete",      // single dash, blacklisted
+			"-avz",         // short options
+			"-rlptgoz",     // short options, looking like a word
+			"-avhxyz",      // short options
+			"-B2048",       // short option with value
+			"--bwrate", "5000",
+		},
+	}
+
+	expected := []string{
+		`rsync argument "-e ssh -oSomething=yes" is not allowed and will be ignored`,
+		`rsync argument "--partial" is not allowed and will be ignored`,
+		`rsync argument "-bwlimit=100" looks like a long option with a single dash`,
+		`rsync argument "-numeric-ids" looks like a long option with a single dash`,
+		`rsync argument "-delete" looks like a long option with a single dash`,
+	}
+	actual := c.checkArgs()
+
+	if !sliceEqual(actual, expected) {
+		t.Errorf("actual=%q, expected=%q\n", actual, expected)
+	}
+}
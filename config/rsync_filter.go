@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// FilterRule is a raw rsync filter rule (see section "FILTER RULES" in
+// rsync(1)), such as "- *.tmp", "dir-merge /.rsync-filter" or "P /srv".
+// Rules are validated when the config is loaded.
+type FilterRule string
+
+// filterRuleNames maps long rule names to their short form.
+var filterRuleNames = map[string]byte{
+	"include":   '+',
+	"exclude":   '-',
+	"merge":     '.',
+	"dir-merge": ':',
+	"hide":      'H',
+	"show":      'S',
+	"protect":   'P',
+	"risk":      'R',
+	"clear":     '!',
+}
+
+// filterRuleModifiers lists the allowed modifiers per (short) rule name.
+var filterRuleModifiers = map[byte]string{
+	'+': "/!Csrpx",
+	'-': "/!Csrpx",
+	'.': "-+Cenwsr",
+	':': "-+Cenwsr",
+	'H': "/!Cx",
+	'S': "/!Cx",
+	'P': "/!Cx",
+	'R': "/!Cx",
+	'!': "",
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (f *FilterRule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	rule := FilterRule(strings.TrimSpace(s))
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	*f = rule
+	return nil
+}
+
+// Validate checks the rule syntax. It does not check the pattern itself.
+func (f FilterRule) Validate() error {
+	s := string(f)
+	if s == "" || s[0] == ' ' || s[0] == '_' {
+		return fmt.Errorf("invalid filter rule %q: missing rule name", s)
+	}
+	// a line break would end the rule, and start another one
+	if strings.IndexFunc(s, unicode.IsControl) >= 0 {
+		return fmt.Errorf("invalid filter rule %q: contains control characters", s)
+	}
+
+	// a rule is "RULE[,MODIFIERS] [PATTERN_OR_FILENAME]" for long names,
+	// and "RULE[MODIFIERS] PATTERN_OR_FILENAME" for short names, where
+	// the separator may also be an underscore for the latter
+	head, pattern := s, ""
+	if i := strings.IndexAny(s, " _"); i >= 0 {
+		head, pattern = s[:i], strings.TrimSpace(s[i+1:])
+	}
+
+	var name byte
+	var mods string
+	if long, m, _ := strings.Cut(head, ","); filterRuleNames[long] != 0 {
+		name, mods = filterRuleNames[long], m
+	} else {
+		name, mods = head[0], head[1:]
+		if _, ok := filterRuleModifiers[name]; !ok {
+			return fmt.Errorf("invalid filter rule %q: unknown rule %q", s, string(name))
+		}
+	}
+
+	for _, m := range mods {
+		if !strings.ContainsRune(filterRuleModifiers[name], m) {
+			return fmt.Errorf("invalid filter rule %q: unsupported modifier %q", s, m)
+		}
+	}
+
+	needsPattern := name != '!' && !strings.ContainsRune(mods, 'C')
+	if needsPattern && pattern == "" {
+		return fmt.Errorf("invalid filter rule %q: missing pattern", s)
+	}
+	if !needsPattern && pattern != "" {
+		return fmt.Errorf("invalid filter rule %q: unexpected pattern", s)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestFilterRuleValidate(t *testing.T) {
+	valid := []FilterRule{
+		"- *.tmp",
+		"+ /home",
+		"-/ /proc",
+		"+_/srv",
+		"exclude /tmp",
+		"include,/ /srv",
+		"dir-merge /.rsync-filter",
+		": /.rsync-filter",
+		":n- .exclude",
+		"merge /etc/zackup/common.rules",
+		"P /srv/keep",
+		"risk /srv/keep/cache",
+		"-C",
+		"!",
+		"clear",
+	}
+	for _, rule := range valid {
+		assert.New(t).NoError(rule.Validate(), string(rule))
+	}
+
+	invalid := []FilterRule{
+		"",
+		" - *.tmp",
+		"_foo",
+		"*.tmp",
+		"-",
+		"+ ",
+		"-z *.tmp",
+		"exclude,z *.tmp",
+		"unknown /foo",
+		"! /foo",
+		"-C /foo",
+		"- *.tmp\n+ /etc/shadow",
+		"- *.tmp\r+ /etc/shadow",
+		"- *.tmp\x00",
+		"-\t*.tmp",
+	}
+	for _, rule := range invalid {
+		assert.New(t).Error(rule.Validate(), string(rule))
+	}
+}
+
+func TestFilterRuleUnmarshal(t *testing.T) {
+	var r RsyncConfig
+	assert.New(t).NoError(yaml.Unmarshal([]byte("filter: ['- *.tmp', 'P /srv']"), &r))
+	assert.New(t).Equal([]FilterRule{"- *.tmp", "P /srv"}, r.Filter)
+
+	assert.New(t).Error(yaml.Unmarshal([]byte("filter: ['*.tmp']"), &r))
+	assert.New(t).Error(yaml.Unmarshal([]byte(`filter: ["- *.tmp\n+ /etc/shadow"]`), &r))
+}
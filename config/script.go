@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"io"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"sort"
@@ -12,20 +13,82 @@ import (
 	"github.com/pkg/errors"
 )
 
-// Script is a set of script lines.
+// Script is a list of script units. Each unit is executed on its own.
 type Script struct {
-	inline  []string // from host yaml
-	scripts []string // from files
+	inline  []ScriptUnit // from yaml files
+	scripts []ScriptUnit // from files
 }
 
-// Lines returns the combined inline and file script lines (in that order).
-func (s *Script) Lines() []string {
-	buf := make([]string, 0, len(s.inline)+len(s.scripts))
+// ScriptUnit is a single inline script or script file.
+type ScriptUnit struct {
+	// Name identifies the source of the script, i.e. the file name
+	// (relative to the tree root), or "file#key" for inline scripts.
+	Name string
+
+	// Interpreter is taken from the shebang line. If empty, the script
+	// is executed with "/bin/sh -esx".
+	Interpreter []string
+
+	// Lines of the script. For the default interpreter, empty lines
+	// and comments are removed. Other scripts are kept verbatim.
+	Lines []string
+
+	// Template is set, if the script starts with TemplateMarker. Only
+	// those scripts are rendered (see Script.Render).
+	Template bool
+}
+
+// Units returns the combined inline and file script units (in that order).
+func (s *Script) Units() []ScriptUnit {
+	buf := make([]ScriptUnit, 0, len(s.inline)+len(s.scripts))
 	buf = append(buf, s.inline...)
 	buf = append(buf, s.scripts...)
 	return buf
 }
 
+// Lines returns the lines of all units (see Units()).
+func (s *Script) Lines() []string {
+	var buf []string
+	for _, u := range s.Units() {
+		buf = append(buf, u.Lines...)
+	}
+	return buf
+}
+
+// clone returns a deep copy of s.
+func (s *Script) clone() Script {
+	return Script{inline: cloneUnits(s.inline), scripts: cloneUnits(s.scripts)}
+}
+
+// inherit prepends the units of globals to s.
+func (s *Script) inherit(globals *Script) {
+	s.inline = append(cloneUnits(globals.inline), s.inline...)
+	s.scripts = append(cloneUnits(globals.scripts), s.scripts...)
+}
+
+func cloneUnits(units []ScriptUnit) []ScriptUnit {
+	if units == nil {
+		return nil
+	}
+	c := make([]ScriptUnit, len(units))
+	for i, u := range units {
+		c[i] = ScriptUnit{
+			Name:        u.Name,
+			Interpreter: cloneStrings(u.Interpreter),
+			Lines:       cloneStrings(u.Lines),
+			Template:    u.Template,
+		}
+	}
+	return c
+}
+
+// setSource names the inline units of s after the given file and key.
+func (s *Script) setSource(file, key string) {
+	for i := range s.inline {
+		s.inline[i].Name = file + "#" + key
+	}
+}
+
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
 func (s *Script) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var inline string
@@ -33,13 +96,60 @@ func (s *Script) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 
-	r := strings.NewReader(inline)
-	lines, err := cleanScript(r)
+	unit, err := parseScript("inline", strings.NewReader(inline))
+	if err != nil {
+		return err
+	}
+
+	*s = Script{}
+	if len(unit.Lines) > 0 {
+		s.inline = []ScriptUnit{unit}
+	}
+	return nil
+}
+
+// MarshalYAML implements the yaml.Marshaler interface. It returns the
+// combined lines (see Lines()), with the shebang of each unit.
+func (s Script) MarshalYAML() (interface{}, error) {
+	var buf strings.Builder
+	for _, u := range s.Units() {
+		if len(u.Interpreter) > 0 {
+			buf.WriteString("#!" + strings.Join(u.Interpreter, " ") + "\n")
+		}
+		if u.Template {
+			buf.WriteString(TemplateMarker + "\n")
+		}
+		for _, line := range u.Lines {
+			buf.WriteString(line + "\n")
+		}
+	}
+	return buf.String(), nil
+}
+
+// SyntaxCheck renders each unit with a sample context, and runs the
+// result through "sh -n" on the local host. Units with a different
+// interpreter are not checked. Note that the remote shell might behave
+// differently.
+func (s *Script) SyntaxCheck() error {
+	units, err := s.Render(sampleContext)
 	if err != nil {
 		return err
 	}
 
-	*s = Script{inline: lines}
+	for _, u := range units {
+		if len(u.Interpreter) > 0 || len(u.Lines) == 0 {
+			continue
+		}
+
+		cmd := exec.Command("/bin/sh", "-n")
+		cmd.Stdin = strings.NewReader(strings.Join(u.Lines, "\n") + "\n")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			if msg := strings.TrimSpace(string(out)); msg != "" {
+				return errors.Errorf("%s: sh -n: %s", u.Name, msg)
+			}
+			return errors.Wrapf(err, "%s: sh -n", u.Name)
+		}
+	}
 	return nil
 }
 
@@ -51,28 +161,69 @@ func (s *Script) readFiles(root, host, pattern string) error {
 
 	sort.Strings(glob)
 	for _, file := range glob {
-		lines, err := readScriptFile(file)
+		unit, err := readScriptFile(file, path.Join(host, path.Base(file)))
 		if err != nil {
 			return err
 		}
-
-		s.scripts = append(s.scripts, lines...)
+		if len(unit.Lines) > 0 {
+			s.scripts = append(s.scripts, unit)
+		}
 	}
 	return nil
 }
 
-func readScriptFile(name string) ([]string, error) {
-	f, err := os.Open(name)
+func readScriptFile(file, name string) (ScriptUnit, error) {
+	f, err := os.Open(file)
 	if err != nil {
-		return nil, err
+		return ScriptUnit{}, err
 	}
 	defer f.Close()
 
-	lines, err := cleanScript(f)
+	unit, err := parseScript(name, f)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to parse %s", name)
+		return ScriptUnit{}, errors.Wrapf(err, "failed to parse %s", file)
+	}
+	return unit, nil
+}
+
+// parseScript reads a script. If it starts with a shebang line, the
+// interpreter is taken from it, and the remaining lines are kept as is.
+// Otherwise, the script is cleaned (see cleanScript). A TemplateMarker
+// in the first (remaining) line is removed, and marks the unit as
+// template.
+func parseScript(name string, r io.Reader) (ScriptUnit, error) {
+	unit := ScriptUnit{Name: name}
+
+	var lines []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		return unit, err
+	}
+
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "#!") {
+		unit.Interpreter = strings.Fields(strings.TrimPrefix(lines[0], "#!"))
+		lines = lines[1:]
+	}
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == TemplateMarker {
+		unit.Template = true
+		lines = lines[1:]
+	}
+
+	if unit.Interpreter == nil {
+		var err error
+		unit.Lines, err = cleanScript(strings.NewReader(strings.Join(lines, "\n")))
+		return unit, err
+	}
+
+	// remove trailing empty lines
+	for n := len(lines); n > 0 && strings.TrimSpace(lines[n-1]) == ""; n-- {
+		lines = lines[:n-1]
 	}
-	return lines, nil
+	unit.Lines = lines
+	return unit, nil
 }
 
 func cleanScript(r io.Reader) (cleaned []string, err error) {
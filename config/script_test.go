@@ -0,0 +1,51 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScript(t *testing.T) {
+	assert := assert.New(t)
+
+	unit, err := parseScript("plain", strings.NewReader("# comment\n\n  echo foo  \n"))
+	assert.NoError(err)
+	assert.Equal(ScriptUnit{Name: "plain", Lines: []string{"echo foo"}}, unit)
+
+	unit, err = parseScript("python", strings.NewReader("#!/usr/bin/env python3\n# comment\nif True:\n    print(1)\n\n"))
+	assert.NoError(err)
+	assert.Equal(ScriptUnit{
+		Name:        "python",
+		Interpreter: []string{"/usr/bin/env", "python3"},
+		Lines:       []string{"# comment", "if True:", "    print(1)"},
+	}, unit)
+}
+
+func TestTreeScriptUnits(t *testing.T) {
+	tr := loadTree(t, map[string]string{
+		"config.yml":                   "---\n",
+		"globals.yml":                  "pre_script: echo global\nscript_timeout: 5m\n",
+		"hosts/example.com/config.yml": "pre_script: |\n  " + TemplateMarker + "\n  echo {{ .Host }}\n",
+		"example.com/pre.1.sh":         "#!/bin/bash\n" + TemplateMarker + "\n[[ -d {{ .Target }} ]]\n",
+		"example.com/pre.2.sh":         "echo file\n",
+	})
+	job := tr.Host("example.com")
+
+	assert := assert.New(t)
+	assert.Equal(5*time.Minute, job.HookTimeout())
+
+	units, err := job.PreScript.Render(&ScriptContext{Host: "example.com", Target: "/backup/example.com"})
+	require.NoError(t, err)
+	assert.Equal([]ScriptUnit{
+		{Name: "globals.yml#pre_script", Lines: []string{"echo global"}},
+		{Name: "hosts/example.com/config.yml#pre_script", Lines: []string{"echo example.com"}, Template: true},
+		{Name: "example.com/pre.1.sh", Interpreter: []string{"/bin/bash"}, Lines: []string{"[[ -d /backup/example.com ]]"}, Template: true},
+		{Name: "example.com/pre.2.sh", Lines: []string{"echo file"}},
+	}, units)
+
+	assert.Equal(DefaultScriptTimeout, (&JobConfig{}).HookTimeout())
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// secretRef matches ${env:NAME} and ${file:/path} references.
+var secretRef = regexp.MustCompile(`\$\{(env|file):([^}]*)\}`)
+
+// secretName matches valid environment variable names.
+var secretName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// A Resolver expands ${env:NAME} and ${file:/path} references at job
+// time. It remembers all resolved values, so that they can be redacted
+// from log output.
+type Resolver struct {
+	values []string
+}
+
+// Expand replaces all references in s with their values. Referencing an
+// unset environment variable or an unreadable file is an error. A single
+// trailing newline is removed from file contents.
+func (r *Resolver) Expand(s string) (string, error) {
+	var err error
+	out := secretRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+		m := secretRef.FindStringSubmatch(ref)
+		var val string
+		if val, err = resolveRef(m[1], m[2]); err != nil {
+			return ref
+		}
+		r.remember(val)
+		return val
+	})
+	return out, err
+}
+
+// Lines expands the references in each line (see Expand).
+func (r *Resolver) Lines(lines []string) ([]string, error) {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		val, err := r.Expand(line)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = val
+	}
+	return out, nil
+}
+
+// Env expands the given secrets and returns them as sorted list of
+// "NAME=value" pairs. All values are considered sensitive, whether they
+// contain references or not.
+func (r *Resolver) Env(secrets map[string]string) ([]string, error) {
+	env := make([]string, 0, len(secrets))
+	for name, raw := range secrets {
+		val, err := r.Expand(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "secret %s", name)
+		}
+		r.remember(val)
+		env = append(env, name+"="+val)
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// Values returns all values resolved so far.
+func (r *Resolver) Values() []string {
+	return r.values
+}
+
+func (r *Resolver) remember(val string) {
+	if val != "" && !contains(r.values, val) {
+		r.values = append(r.values, val)
+	}
+}
+
+func resolveRef(kind, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty %s reference", kind)
+	}
+	switch kind {
+	case "env":
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return val, nil
+	case "file":
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return "", errors.Wrap(err, "reading secret file")
+		}
+		return strings.TrimSuffix(string(data), "\n"), nil
+	}
+	return "", fmt.Errorf("unknown reference type %q", kind)
+}
+
+// checkRefs validates the references in s without resolving them.
+func checkRefs(s string) []string {
+	var problems []string
+	for _, m := range secretRef.FindAllStringSubmatch(s, -1) {
+		switch {
+		case m[2] == "":
+			problems = append(problems, fmt.Sprintf("empty %s reference %q", m[1], m[0]))
+		case m[1] == "env" && !secretName.MatchString(m[2]):
+			problems = append(problems, fmt.Sprintf("invalid environment variable name in %q", m[0]))
+		case m[1] == "file" && !strings.HasPrefix(m[2], "/"):
+			problems = append(problems, fmt.Sprintf("file reference %q must be an absolute path", m[0]))
+		}
+	}
+	return problems
+}
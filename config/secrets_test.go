@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolverExpand(t *testing.T) {
+	t.Setenv("ZACKUP_TEST_SECRET", "s3cr3t")
+	file := filepath.Join(t.TempDir(), "pw")
+	if err := os.WriteFile(file, []byte("hunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+	var r Resolver
+
+	val, err := r.Expand("mysqldump -p${env:ZACKUP_TEST_SECRET} --all")
+	assert.NoError(err)
+	assert.Equal("mysqldump -ps3cr3t --all", val)
+
+	val, err = r.Expand("PGPASSWORD=${file:" + file + "} pg_dumpall ${HOME}")
+	assert.NoError(err)
+	assert.Equal("PGPASSWORD=hunter2 pg_dumpall ${HOME}", val)
+
+	_, err = r.Expand("${env:ZACKUP_TEST_UNSET}")
+	assert.EqualError(err, "environment variable ZACKUP_TEST_UNSET is not set")
+
+	_, err = r.Expand("${file:/nonexistent}")
+	assert.Error(err)
+
+	assert.Equal([]string{"s3cr3t", "hunter2"}, r.Values())
+}
+
+func TestResolverEnv(t *testing.T) {
+	t.Setenv("ZACKUP_TEST_SECRET", "s3cr3t")
+
+	var r Resolver
+	env, err := r.Env(map[string]string{
+		"PLAIN": "plain text",
+		"REF":   "${env:ZACKUP_TEST_SECRET}",
+	})
+
+	assert := assert.New(t)
+	assert.NoError(err)
+	assert.Equal([]string{"PLAIN=plain text", "REF=s3cr3t"}, env)
+	assert.ElementsMatch([]string{"plain text", "s3cr3t"}, r.Values())
+}
+
+func TestCheckRefs(t *testing.T) {
+	assert := assert.New(t)
+	assert.Empty(checkRefs("echo ${env:FOO} ${file:/etc/secret} ${BAR}"))
+	assert.Equal([]string{
+		`empty env reference "${env:}"`,
+		`invalid environment variable name in "${env:1FOO}"`,
+		`file reference "${file:secret}" must be an absolute path`,
+	}, checkRefs("${env:} ${env:1FOO} ${file:secret}"))
+}
@@ -16,9 +16,17 @@ type ServiceConfig struct {
 	MountBase   string `yaml:"mount_base"`
 	LogLevel    string `yaml:"log_level"`
 
+	// Strict refuses to run backups, if the config contains problems
+	// (see Tree.Findings), instead of only logging them.
+	Strict bool `yaml:"strict"`
+
 	RSyncPath string `yaml:"rsync_bin"`
 	SSHPath   string `yaml:"ssh_bin"`
 
+	// BandwidthGroups maps group names to an aggregate limit, which is
+	// shared by all concurrently running jobs of that group.
+	BandwidthGroups map[string]Rate `yaml:"bandwidth_groups"`
+
 	Daemon struct {
 		Schedule schedule `yaml:"schedule"`
 		Jitter   duration `yaml:"jitter"`
@@ -33,7 +41,7 @@ func (d *duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 
-	dur, err := time.ParseDuration(s)
+	dur, err := ParseDuration(s)
 	if err != nil {
 		return err
 	}
@@ -42,6 +50,11 @@ func (d *duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// MarshalYAML implements the yaml.Marshaler interface.
+func (d duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
 type schedule struct {
 	h, m, s int // hour, minute and second values
 }
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Source snapshot types.
+const (
+	SnapshotZFS   = "zfs"
+	SnapshotLVM   = "lvm"
+	SnapshotBtrfs = "btrfs"
+)
+
+// Defaults for SourceSnapshotConfig.
+const (
+	DefaultSnapshotMount   = "/run/zackup/snapshot"
+	DefaultSnapshotSize    = "1G"
+	DefaultSnapshotOptions = "ro"
+)
+
+// snapshotName is used for the temporary snapshot on the remote host.
+const snapshotName = "zackup-tmp"
+
+// SourceSnapshotConfig enables a temporary snapshot on the remote host,
+// which is used as rsync source instead of the live file system. This
+// gives consistent copies of e.g. databases and mail spools.
+type SourceSnapshotConfig struct {
+	// Type is one of "zfs", "lvm" or "btrfs".
+	Type string `yaml:"type"`
+
+	// Source names the file system to snapshot: a ZFS dataset (e.g.
+	// "rpool/ROOT/debian"), an LVM logical volume (e.g. "vg0/root") or
+	// the path of a btrfs subvolume (e.g. "/").
+	Source string `yaml:"source"`
+
+	// Mount is the mount point for ZFS and LVM snapshots on the remote
+	// host. Defaults to DefaultSnapshotMount.
+	Mount string `yaml:"mount"`
+
+	// Size of the copy-on-write area for LVM snapshots. Defaults to
+	// DefaultSnapshotSize.
+	Size string `yaml:"size"`
+
+	// Options are passed to mount(8) for LVM snapshots. Defaults to
+	// DefaultSnapshotOptions ("ro,nouuid" is required for XFS).
+	Options string `yaml:"mount_options"`
+}
+
+func (s *SourceSnapshotConfig) clone() *SourceSnapshotConfig {
+	dup := *s
+	return &dup
+}
+
+// Path returns the path of the snapshot on the remote host, i.e. the
+// rsync source directory.
+func (s *SourceSnapshotConfig) Path() string {
+	if s.Type == SnapshotBtrfs {
+		return path.Join(s.Source, ".zackup-snapshot")
+	}
+	if s.Mount == "" {
+		return DefaultSnapshotMount
+	}
+	return s.Mount
+}
+
+// errUnknownType returns the error for scripts of an unknown snapshot type.
+func (s *SourceSnapshotConfig) errUnknownType() error {
+	return fmt.Errorf("unknown source_snapshot.type %q", s.Type)
+}
+
+// CreateScript returns a script creating and mounting the snapshot.
+// Leftovers from previous runs must be removed with DestroyScript first.
+func (s *SourceSnapshotConfig) CreateScript() ([]string, error) {
+	mnt := ShellQuote(s.Path())
+
+	switch s.Type {
+	case SnapshotZFS:
+		snap := ShellQuote(s.Source + "@" + snapshotName)
+		return []string{
+			"zfs snapshot " + snap,
+			"mkdir -p " + mnt,
+			"mount -t zfs " + snap + " " + mnt,
+		}, nil
+	case SnapshotLVM:
+		size, opts := s.Size, s.Options
+		if size == "" {
+			size = DefaultSnapshotSize
+		}
+		if opts == "" {
+			opts = DefaultSnapshotOptions
+		}
+		return []string{
+			fmt.Sprintf("lvcreate -s -n %s -L %s %s",
+				ShellQuote(path.Base(s.lvmSnapshot())), ShellQuote(size), ShellQuote(s.Source)),
+			"mkdir -p " + mnt,
+			fmt.Sprintf("mount -o %s %s %s", ShellQuote(opts), ShellQuote("/dev/"+s.lvmSnapshot()), mnt),
+		}, nil
+	case SnapshotBtrfs:
+		return []string{
+			fmt.Sprintf("btrfs subvolume snapshot -r %s %s", ShellQuote(s.Source), mnt),
+		}, nil
+	}
+	return nil, s.errUnknownType()
+}
+
+// DestroyScript returns a script unmounting and removing the snapshot.
+// It succeeds, if there is no snapshot.
+func (s *SourceSnapshotConfig) DestroyScript() ([]string, error) {
+	mnt := ShellQuote(s.Path())
+
+	switch s.Type {
+	case SnapshotZFS:
+		snap := ShellQuote(s.Source + "@" + snapshotName)
+		return []string{
+			"umount " + mnt + " 2>/dev/null || true",
+			"if zfs list -H -t snapshot " + snap + " >/dev/null 2>&1; then zfs destroy " + snap + "; fi",
+		}, nil
+	case SnapshotLVM:
+		lv := ShellQuote(s.lvmSnapshot())
+		return []string{
+			"umount " + mnt + " 2>/dev/null || true",
+			"if lvs " + lv + " >/dev/null 2>&1; then lvremove -f " + lv + "; fi",
+		}, nil
+	case SnapshotBtrfs:
+		return []string{
+			"if [ -d " + mnt + " ]; then btrfs subvolume delete " + mnt + "; fi",
+		}, nil
+	}
+	return nil, s.errUnknownType()
+}
+
+// mountCheck is an awk program reading the mount table. It fails, if
+// any of the paths in incl (relative to root, the mount point of src)
+// is not on src, or contains a mount of the same file system type (e.g.
+// a child dataset), which is not below one of the paths in excl. Those
+// would show up empty in the snapshot, and rsync would delete them from
+// the backup. root is looked up in the mount table, if empty.
+const mountCheck = `
+function unescape(s) { gsub(/\\040/, " ", s); gsub(/\\011/, "\t", s); return s }
+function below(m, p) { return m == "/" || p == m || index(p, m "/") == 1 }
+function fail(msg) { print "source_snapshot: " msg > "/dev/stderr"; err = 1 }
+{ dev[NR] = unescape($1); mnt[NR] = unescape($2); typ[NR] = $3 }
+END {
+	if (root == "")
+		for (i = 1; i <= NR; i++)
+			if (dev[i] == src) root = mnt[i]
+	if (root == "") { fail(src " is not mounted"); exit err }
+	for (i = 1; i <= NR; i++)
+		if (below(mnt[i], root) && length(mnt[i]) >= length(best)) { best = mnt[i]; fstype = typ[i] }
+	nx = split(excl, skip, "\n")
+	for (k = 1; k <= nx; k++)
+		skip[k] = skip[k] == "/" ? root : (root == "/" ? "" : root) skip[k]
+	n = split(incl, list, "\n")
+	for (j = 1; j <= n; j++) {
+		p = list[j] == "/" ? root : (root == "/" ? "" : root) list[j]
+		for (i = 1; i <= NR; i++) {
+			if (mnt[i] == root || !below(root, mnt[i])) continue
+			for (k = 1; k <= nx && !below(skip[k], mnt[i]); k++);
+			if (k <= nx) continue
+			if (below(mnt[i], p)) fail(list[j] " is on " dev[i] ", not on " src)
+			else if (typ[i] == fstype && below(p, mnt[i])) fail(list[j] " contains " mnt[i] " (" dev[i] "), which is not part of the snapshot")
+		}
+	}
+	exit err
+}`
+
+// CheckScript returns a script, which checks that the given paths
+// (absolute, relative to the root of the snapshot) are completely on the
+// snapshotted file system. Mounts below the excluded paths are ignored.
+// Nested btrfs subvolumes are not detected.
+func (s *SourceSnapshotConfig) CheckScript(paths, excluded []string) ([]string, error) {
+	var script []string
+	root := "''"
+	switch s.Type {
+	case SnapshotZFS:
+	case SnapshotLVM:
+		script = append(script, "root=$(findmnt -n -f -o TARGET "+ShellQuote("/dev/"+s.Source)+" || true)")
+		root = `"$root"`
+	case SnapshotBtrfs:
+		root = ShellQuote(path.Clean(s.Source))
+	default:
+		return nil, s.errUnknownType()
+	}
+
+	return append(script,
+		"{ if [ -r /proc/self/mounts ]; then cat /proc/self/mounts; else mount -p; fi; } | awk"+
+			" -v src="+ShellQuote(s.Source)+
+			" -v root="+root+
+			" -v incl="+ShellQuote(strings.Join(paths, `\n`))+
+			" -v excl="+ShellQuote(strings.Join(excluded, `\n`))+
+			" "+ShellQuote(mountCheck),
+	), nil
+}
+
+// lvmSnapshot returns the name ("vg/lv") of the LVM snapshot volume.
+func (s *SourceSnapshotConfig) lvmSnapshot() string {
+	return s.Source + "-" + snapshotName
+}
+
+// check returns problems with s.
+func (s *SourceSnapshotConfig) check() (problems []string) {
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case SnapshotZFS, SnapshotLVM, SnapshotBtrfs:
+	default:
+		add("unknown source_snapshot.type %q, expected zfs, lvm or btrfs", s.Type)
+	}
+
+	switch {
+	case s.Source == "":
+		add("source_snapshot.source is missing")
+	case s.Type == SnapshotZFS && (strings.HasPrefix(s.Source, "/") || strings.Contains(s.Source, "@")):
+		add("source_snapshot.source %q must be a ZFS dataset", s.Source)
+	case s.Type == SnapshotLVM && !isLogicalVolume(s.Source):
+		add("source_snapshot.source %q must be a logical volume (vg/lv)", s.Source)
+	case s.Type == SnapshotBtrfs && !path.IsAbs(s.Source):
+		add("source_snapshot.source %q must be an absolute path", s.Source)
+	}
+
+	if s.Mount != "" && (!path.IsAbs(s.Mount) || path.Clean(s.Mount) == "/") {
+		add("source_snapshot.mount %q must be an absolute path other than /", s.Mount)
+	}
+	return problems
+}
+
+func isLogicalVolume(name string) bool {
+	parts := strings.Split(name, "/")
+	return len(parts) == 2 && parts[0] != "" && parts[1] != ""
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceSnapshotScripts(t *testing.T) {
+	assert := assert.New(t)
+	must := func(script []string, err error) []string {
+		assert.NoError(err)
+		return script
+	}
+
+	zfs := &SourceSnapshotConfig{Type: SnapshotZFS, Source: "rpool/ROOT/debian"}
+	assert.Equal(DefaultSnapshotMount, zfs.Path())
+	assert.Equal([]string{
+		"zfs snapshot 'rpool/ROOT/debian@zackup-tmp'",
+		"mkdir -p '/run/zackup/snapshot'",
+		"mount -t zfs 'rpool/ROOT/debian@zackup-tmp' '/run/zackup/snapshot'",
+	}, must(zfs.CreateScript()))
+	assert.Equal([]string{
+		"umount '/run/zackup/snapshot' 2>/dev/null || true",
+		"if zfs list -H -t snapshot 'rpool/ROOT/debian@zackup-tmp' >/dev/null 2>&1; then zfs destroy 'rpool/ROOT/debian@zackup-tmp'; fi",
+	}, must(zfs.DestroyScript()))
+
+	lvm := &SourceSnapshotConfig{Type: SnapshotLVM, Source: "vg0/root", Mount: "/mnt/snap", Options: "ro,nouuid"}
+	assert.Equal("/mnt/snap", lvm.Path())
+	assert.Equal([]string{
+		"lvcreate -s -n 'root-zackup-tmp' -L '1G' 'vg0/root'",
+		"mkdir -p '/mnt/snap'",
+		"mount -o 'ro,nouuid' '/dev/vg0/root-zackup-tmp' '/mnt/snap'",
+	}, must(lvm.CreateScript()))
+	assert.Contains(must(lvm.DestroyScript()), "if lvs 'vg0/root-zackup-tmp' >/dev/null 2>&1; then lvremove -f 'vg0/root-zackup-tmp'; fi")
+
+	btrfs := &SourceSnapshotConfig{Type: SnapshotBtrfs, Source: "/"}
+	assert.Equal("/.zackup-snapshot", btrfs.Path())
+	assert.Equal([]string{
+		"btrfs subvolume snapshot -r '/' '/.zackup-snapshot'",
+	}, must(btrfs.CreateScript()))
+	assert.Equal([]string{
+		"if [ -d '/.zackup-snapshot' ]; then btrfs subvolume delete '/.zackup-snapshot'; fi",
+	}, must(btrfs.DestroyScript()))
+}
+
+func TestSourceSnapshotUnknownType(t *testing.T) {
+	snap := &SourceSnapshotConfig{Type: "lvm2", Source: "vg0/root"}
+	for _, fn := range []func() ([]string, error){snap.CreateScript, snap.DestroyScript} {
+		_, err := fn()
+		assert.EqualError(t, err, `unknown source_snapshot.type "lvm2"`)
+	}
+	_, err := snap.CheckScript([]string{"/"}, nil)
+	assert.Error(t, err)
+}
+
+func TestSourceSnapshotMountCheck(t *testing.T) {
+	if _, err := exec.LookPath("awk"); err != nil {
+		t.Skip("awk not found")
+	}
+
+	table := strings.Join([]string{
+		"rpool/ROOT/debian / zfs rw 0 0",
+		"proc /proc proc rw 0 0",
+		"rpool/home /home zfs rw 0 0",
+		"rpool/var/log /var/log zfs rw 0 0",
+		"tmpfs /run tmpfs rw 0 0",
+		"/dev/sdb1 /srv/my\\040data ext4 rw 0 0",
+	}, "\n")
+
+	tests := []struct {
+		paths    []string
+		excluded []string
+		expected string
+	}{
+		{[]string{"/etc", "/root"}, nil, ""},
+		{[]string{"/"}, []string{"/home", "/var"}, ""},
+		{[]string{"/"}, nil, "source_snapshot: / contains /home (rpool/home), which is not part of the snapshot\n" +
+			"source_snapshot: / contains /var/log (rpool/var/log), which is not part of the snapshot\n"},
+		{[]string{"/var"}, nil, "source_snapshot: /var contains /var/log (rpool/var/log), which is not part of the snapshot\n"},
+		{[]string{"/home/alice"}, nil, "source_snapshot: /home/alice is on rpool/home, not on rpool/ROOT/debian\n"},
+		{[]string{"/run/foo"}, nil, "source_snapshot: /run/foo is on tmpfs, not on rpool/ROOT/debian\n"},
+		{[]string{"/srv/my data/x"}, nil, "source_snapshot: /srv/my data/x is on /dev/sdb1, not on rpool/ROOT/debian\n"},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("awk", "-v", "src=rpool/ROOT/debian", "-v", "root=",
+			"-v", "incl="+strings.Join(tt.paths, `\n`), "-v", "excl="+strings.Join(tt.excluded, `\n`), mountCheck)
+		cmd.Stdin = strings.NewReader(table + "\n")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		assert.Equal(t, tt.expected, stderr.String(), "%v", tt.paths)
+		assert.Equal(t, tt.expected != "", err != nil, "%v", tt.paths)
+	}
+}
+
+func TestSourceSnapshotCheck(t *testing.T) {
+	tests := []struct {
+		snap     SourceSnapshotConfig
+		problems []string
+	}{
+		{SourceSnapshotConfig{Type: "zfs", Source: "rpool/ROOT/debian"}, nil},
+		{SourceSnapshotConfig{Type: "lvm", Source: "vg0/root"}, nil},
+		{SourceSnapshotConfig{Type: "btrfs", Source: "/home"}, nil},
+		{SourceSnapshotConfig{Type: "xfs", Source: "/"}, []string{
+			`unknown source_snapshot.type "xfs", expected zfs, lvm or btrfs`,
+		}},
+		{SourceSnapshotConfig{Type: "zfs"}, []string{
+			"source_snapshot.source is missing",
+		}},
+		{SourceSnapshotConfig{Type: "zfs", Source: "rpool@snap", Mount: "/"}, []string{
+			`source_snapshot.source "rpool@snap" must be a ZFS dataset`,
+			`source_snapshot.mount "/" must be an absolute path other than /`,
+		}},
+		{SourceSnapshotConfig{Type: "lvm", Source: "/dev/vg0/root"}, []string{
+			`source_snapshot.source "/dev/vg0/root" must be a logical volume (vg/lv)`,
+		}},
+		{SourceSnapshotConfig{Type: "btrfs", Source: "home"}, []string{
+			`source_snapshot.source "home" must be an absolute path`,
+		}},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.problems, tc.snap.check(), "%+v", tc.snap)
+	}
+}
+
+func TestRsyncBuildArgVectorSourcePath(t *testing.T) {
+	r := &RsyncConfig{Included: []string{"/etc"}}
+	args := r.BuildArgVector(RsyncSource{Shell: "ssh", Base: "root@host:", Path: "/run/zackup/snapshot"}, "/zackup/host", ArgOptions{})
+
+	assert.New(t).Contains(args, "--include=/etc")
+	assert.New(t).Equal([]string{"root@host:/run/zackup/snapshot/", "/zackup/host/"}, args[len(args)-2:])
+}
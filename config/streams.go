@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+)
+
+// StreamsDir is the directory within a host's backup (MountBase/host),
+// which receives the output of stream commands.
+const StreamsDir = ".zackup-streams"
+
+// DefaultStreamTimeout is the default value for StreamConfig.Timeout.
+const DefaultStreamTimeout = 6 * time.Hour
+
+// StreamConfig pipes the output of a command on the remote host into a
+// file in StreamsDir, e.g. a database dump.
+type StreamConfig struct {
+	// Name of the file in StreamsDir.
+	Name string `yaml:"name"`
+
+	// Command is executed with "/bin/sh -esx" (and pipefail, if the
+	// remote shell supports it). Its stdout is written to the file.
+	// Like hook scripts, it is rendered as template and may contain
+	// secret references.
+	Command string `yaml:"command"`
+
+	// MinSize is the minimum size of the output. Defaults to 1 byte, i.e.
+	// empty output is an error.
+	MinSize ByteSize `yaml:"min_size"`
+
+	// Timeout limits the run time of Command. Defaults to
+	// DefaultStreamTimeout.
+	Timeout *duration `yaml:"timeout"`
+}
+
+// ByteSize is a size in bytes. In YAML, it is either a plain number or
+// a string with unit, e.g. "10 MB" or "1GiB".
+type ByteSize uint64
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (b *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var n uint64
+	if err := unmarshal(&n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	n, err := humanize.ParseBytes(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	*b = ByteSize(n)
+	return nil
+}
+
+func (b ByteSize) String() string {
+	return humanize.IBytes(uint64(b))
+}
+
+// MinBytes returns the minimum output size (see MinSize).
+func (s *StreamConfig) MinBytes() uint64 {
+	if s.MinSize == 0 {
+		return 1
+	}
+	return uint64(s.MinSize)
+}
+
+// MaxDuration returns the maximum run time of the command (see Timeout).
+func (s *StreamConfig) MaxDuration() time.Duration {
+	if s.Timeout == nil || *s.Timeout <= 0 {
+		return DefaultStreamTimeout
+	}
+	return time.Duration(*s.Timeout)
+}
+
+// unitName returns the name used for rendering and in script results.
+func (s *StreamConfig) unitName() string {
+	return "streams/" + s.Name
+}
+
+// Render executes the command as template with the given context.
+func (s *StreamConfig) Render(ctx *ScriptContext) (ScriptUnit, error) {
+	tpl, err := parseTemplate(s.unitName(), s.Command)
+	if err != nil {
+		return ScriptUnit{}, errors.Wrap(err, "parsing stream template")
+	}
+
+	var buf strings.Builder
+	if err = tpl.Execute(&buf, ctx); err != nil {
+		return ScriptUnit{}, errors.Wrap(err, "rendering stream template")
+	}
+	return ScriptUnit{
+		Name:  s.unitName(),
+		Lines: strings.Split(strings.TrimSpace(buf.String()), "\n"),
+	}, nil
+}
+
+// checkStreams returns problems with the given streams.
+func checkStreams(streams []StreamConfig) (problems []string) {
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	seen := make(map[string]bool)
+	for i, s := range streams {
+		switch {
+		case s.Name == "":
+			add("streams[%d].name is missing", i)
+		case s.Name == "." || s.Name == ".." || !plainName.MatchString(s.Name):
+			add("streams[%d].name %q must be a plain file name", i, s.Name)
+		case seen[s.Name]:
+			add("streams[%d].name %q is not unique", i, s.Name)
+		}
+		seen[s.Name] = true
+
+		if strings.TrimSpace(s.Command) == "" {
+			add("streams[%d].command is missing", i)
+			continue
+		}
+		if err := checkTemplate(s.unitName(), []string{s.Command}); err != nil {
+			add("streams[%d].command: %v", i, err)
+		}
+		for _, problem := range checkRefs(s.Command) {
+			add("streams[%d].command: %s", i, problem)
+		}
+	}
+	return problems
+}
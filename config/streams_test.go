@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeStreams(t *testing.T) {
+	tr := loadTree(t, map[string]string{
+		"config.yml":  "---\n",
+		"globals.yml": "streams:\n- name: packages.txt\n  command: dpkg --get-selections\n- name: mysql.sql.zst\n  command: echo global\n",
+		"hosts/example.com.yml": "streams:\n" +
+			"- name: mysql.sql.zst\n  command: mysqldump --all-databases | zstd\n  min_size: 1 KiB\n  timeout: 2h\n",
+		"hosts/invalid.com.yml": "streams:\n" +
+			"- name: ../etc/passwd\n  command: echo {{ .Hots }}\n" +
+			"- name: a\n" +
+			"- name: a\n  command: echo ${env:}\n" +
+			"- name: a,b=1\n  command: echo\n",
+	})
+
+	assert := assert.New(t)
+	job := tr.Host("example.com")
+	require.Len(t, job.Streams, 2)
+	assert.Equal("mysql.sql.zst", job.Streams[0].Name)
+	assert.Equal(uint64(1024), job.Streams[0].MinBytes())
+	assert.Equal(2*time.Hour, job.Streams[0].MaxDuration())
+	assert.Equal("packages.txt", job.Streams[1].Name)
+	assert.Equal(uint64(1), job.Streams[1].MinBytes())
+	assert.Equal(DefaultStreamTimeout, job.Streams[1].MaxDuration())
+
+	u, err := job.Streams[0].Render(sampleContext)
+	assert.NoError(err)
+	assert.Equal(ScriptUnit{Name: "streams/mysql.sql.zst", Lines: []string{"mysqldump --all-databases | zstd"}}, u)
+
+	var msgs []string
+	for _, f := range tr.Findings() {
+		if f.Host == "invalid.com" {
+			msgs = append(msgs, f.Message)
+		}
+	}
+	assert.Equal([]string{
+		`streams[0].name "../etc/passwd" must be a plain file name`,
+		`streams[0].command: template: streams/../etc/passwd:1:8: executing "streams/../etc/passwd" at <.Hots>: can't evaluate field Hots in type *config.ScriptContext`,
+		"streams[1].command is missing",
+		`streams[2].name "a" is not unique`,
+		`streams[2].command: empty env reference "${env:}"`,
+		`streams[3].name "a,b=1" must be a plain file name`,
+	}, msgs)
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TemplateMarker must be the first line of a hook script (after the
+// shebang line, if any), to render it as text/template.
+const TemplateMarker = "# zackup:template"
+
+// ScriptContext is passed to hook scripts, which are rendered as
+// text/template before execution (see TemplateMarker), and to stream
+// commands.
+type ScriptContext struct {
+	Host     string    // host name
+	Dataset  string    // ZFS dataset receiving the backup
+	Target   string    // mount point of Dataset (i.e. the rsync destination)
+	Snapshot string    // name of the snapshot taken after a successful run
+	Started  time.Time // start of the run
+	Attempt  uint      // 1 + number of failed runs since the last success
+	Full     bool      // whether this is a full run (see FullEvery)
+	Include  []string  // merged rsync include list
+	DryRun   bool      // whether this is a dry run (see "zackup run --dry-run")
+}
+
+// sampleContext is used to check templates at load time.
+var sampleContext = &ScriptContext{
+	Host:     "example.com",
+	Dataset:  "zpool/zackup/example.com",
+	Target:   "/zpool/zackup/example.com",
+	Snapshot: "zpool/zackup/example.com@2006-01-02T15:04:05Z",
+	Started:  time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+	Attempt:  1,
+	Include:  []string{"/etc"},
+}
+
+var templateFuncs = template.FuncMap{
+	"quote": ShellQuote,
+	"join":  strings.Join,
+}
+
+// ShellQuote quotes s for use in a POSIX shell.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).
+		Option("missingkey=error").
+		Funcs(templateFuncs).
+		Parse(text)
+}
+
+// checkTemplate parses and executes the given script lines against a
+// sample context, to detect syntax errors and unknown fields.
+func checkTemplate(name string, lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	tpl, err := parseTemplate(name, strings.Join(lines, "\n"))
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	return tpl.Execute(io.Discard, sampleContext) //nolint:wrapcheck
+}
+
+// Render executes each template unit (see TemplateMarker) with the given
+// context, and returns the resulting units. For the default interpreter,
+// the result is cleaned again (see cleanScript). Other units are returned
+// unchanged.
+func (s *Script) Render(ctx *ScriptContext) ([]ScriptUnit, error) {
+	units := cloneUnits(s.Units())
+	rendered := make([]ScriptUnit, 0, len(units))
+
+	for _, u := range units {
+		if !u.Template {
+			rendered = append(rendered, u)
+			continue
+		}
+
+		tpl, err := parseTemplate(u.Name, strings.Join(u.Lines, "\n"))
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing script template")
+		}
+
+		var buf strings.Builder
+		if err = tpl.Execute(&buf, ctx); err != nil {
+			return nil, errors.Wrap(err, "rendering script template")
+		}
+
+		r := ScriptUnit{Name: u.Name, Interpreter: u.Interpreter, Template: true}
+		if len(u.Interpreter) > 0 {
+			r.Lines = strings.Split(buf.String(), "\n")
+		} else if r.Lines, err = cleanScript(strings.NewReader(buf.String())); err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, r)
+	}
+	return rendered, nil
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptRender(t *testing.T) {
+	var s Script
+	err := s.UnmarshalYAML(func(v interface{}) error {
+		*(v.(*string)) = strings.Join([]string{
+			TemplateMarker,
+			`echo {{ .Host }} {{ .Dataset | quote }}`,
+			`{{ if .Full }}`,
+			`echo full run`,
+			`{{ end }}`,
+			`{{ range .Include }}`,
+			`tar -cf - {{ quote . }}`,
+			`{{ end }}`,
+			`test {{ .Attempt }} -eq 1 || echo retry`,
+		}, "\n")
+		return nil
+	})
+
+	assert := assert.New(t)
+	assert.NoError(err)
+	assert.Len(s.Units(), 1)
+
+	units, err := s.Render(&ScriptContext{
+		Host:    "example.com",
+		Dataset: "zpool/example.com",
+		Started: time.Now(),
+		Attempt: 2,
+		Include: []string{"/etc", "/srv/it's"},
+	})
+	assert.NoError(err)
+	assert.True(units[0].Template)
+	assert.Equal([]string{
+		`echo example.com 'zpool/example.com'`,
+		`tar -cf - '/etc'`,
+		`tar -cf - '/srv/it'\''s'`,
+		`test 2 -eq 1 || echo retry`,
+	}, units[0].Lines)
+}
+
+func TestScriptRenderOptIn(t *testing.T) {
+	assert := assert.New(t)
+
+	plain, err := parseScript("plain.sh", strings.NewReader("echo '{{ .Host }}'\n"))
+	assert.NoError(err)
+	assert.False(plain.Template)
+
+	python, err := parseScript("hook.py", strings.NewReader("#!/usr/bin/python3\n"+TemplateMarker+"\nprint('{{ .Host }}')\n"))
+	assert.NoError(err)
+	assert.True(python.Template)
+	assert.Equal([]string{"print('{{ .Host }}')"}, python.Lines)
+
+	s := Script{scripts: []ScriptUnit{plain, python}}
+	units, err := s.Render(sampleContext)
+	assert.NoError(err)
+	assert.Equal([]string{"echo '{{ .Host }}'"}, units[0].Lines)
+	assert.Equal([]string{"print('example.com')"}, units[1].Lines)
+
+	out, err := s.MarshalYAML()
+	assert.NoError(err)
+	assert.Equal("echo '{{ .Host }}'\n#!/usr/bin/python3\n"+TemplateMarker+"\nprint('{{ .Host }}')\n", out)
+}
+
+func TestCheckTemplate(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(checkTemplate("ok", []string{"echo {{ .Host }}", "echo ${HOME}"}))
+	assert.EqualError(checkTemplate("syntax", []string{"{{ if .Full }}"}),
+		"template: syntax:1: unexpected EOF")
+	assert.Error(checkTemplate("field", []string{"echo {{ .Hots }}"}))
+}
+
+func TestTreeFindingsTemplate(t *testing.T) {
+	tr := loadTree(t, map[string]string{
+		"config.yml":                   "---\n",
+		"globals.yml":                  "---\n",
+		"hosts/example.com/config.yml": "pre_script: |\n  " + TemplateMarker + "\n  echo {{ .Hots }}\n",
+		"example.com/post.1.sh":        TemplateMarker + "\n{{ if .Full }}\necho full\n",
+		"example.com/post.2.sh":        "echo {{ not a template\n",
+	})
+
+	var msgs []string
+	for _, f := range tr.Findings() {
+		if f.Host == "example.com" {
+			msgs = append(msgs, f.Message)
+		}
+	}
+	assert := assert.New(t)
+	if assert.Len(msgs, 2) {
+		assert.Contains(msgs[0], "can't evaluate field Hots")
+		assert.Equal("post_script: template: example.com/post.1.sh:2: unexpected EOF", msgs[1])
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Transport types.
+const (
+	TransportSSH     = "ssh"
+	TransportLocal   = "local"
+	TransportRsyncd  = "rsyncd"
+	TransportZFSSend = "zfs-send"
+	TransportPush    = "push"
+)
+
+// TransportConfig selects how zackup reaches the data of a host.
+type TransportConfig struct {
+	// Type is one of "ssh" (the default), "local", "rsyncd", "zfs-send"
+	// or "push".
+	Type string `yaml:"type"`
+
+	// Path is the source directory on the backup server (local only).
+	Path string `yaml:"path"`
+
+	// Module is the rsync daemon module, optionally followed by a path
+	// within the module, e.g. "backup" or "backup/www" (rsyncd only).
+	Module string `yaml:"module"`
+
+	// User for the rsync daemon (rsyncd only).
+	User string `yaml:"user"`
+
+	// Port of the rsync daemon, defaults to 873 (rsyncd only).
+	Port uint16 `yaml:"port"`
+
+	// PasswordFile is passed to rsync as --password-file (rsyncd only).
+	PasswordFile string `yaml:"password_file"`
+
+	// Datasets on the remote host, which are replicated with zfs send
+	// into RootDataset/host/dataset (zfs-send only).
+	Datasets []string `yaml:"datasets"`
+}
+
+// TransportType returns the configured transport type, defaulting to
+// "ssh".
+func (j *JobConfig) TransportType() string {
+	if j.Transport == nil || j.Transport.Type == "" {
+		return TransportSSH
+	}
+	return j.Transport.Type
+}
+
+// HasShell reports whether scripts can be executed for this job, i.e.
+// on the remote host (ssh, zfs-send, push) or on the backup server
+// (local).
+func (j *JobConfig) HasShell() bool {
+	return j.TransportType() != TransportRsyncd
+}
+
+// IsPush reports whether the host pushes its data with "zackup agent",
+// instead of being pulled by the server.
+func (j *JobConfig) IsPush() bool {
+	return j.TransportType() == TransportPush
+}
+
+// RsyncSource describes where rsync copies from.
+type RsyncSource struct {
+	// Shell is the remote shell (rsync -e), empty for local and daemon
+	// sources.
+	Shell string
+
+	// Base is prefixed to Path, e.g. "user@host:" or "rsync://host/module".
+	// It is empty for local sources.
+	Base string
+
+	// Path is the source directory, relative to Base. Defaults to "/".
+	Path string
+
+	// Args are transport specific rsync arguments (e.g. --password-file).
+	Args []string
+}
+
+// String returns the rsync source argument, with a trailing slash.
+func (s RsyncSource) String() string {
+	src := s.Base + path.Clean("/"+s.Path)
+	if !strings.HasSuffix(src, "/") {
+		src += "/"
+	}
+	return src
+}
+
+// DaemonSource returns the rsync source for the rsyncd transport of
+// the given host.
+func (t *TransportConfig) DaemonSource(host string) RsyncSource {
+	base := "rsync://"
+	if t.User != "" {
+		base += t.User + "@"
+	}
+	base += host
+	if t.Port != 0 {
+		base += fmt.Sprintf(":%d", t.Port)
+	}
+	base += "/" + strings.Trim(t.Module, "/")
+
+	var args []string
+	if t.PasswordFile != "" {
+		args = append(args, "--password-file="+t.PasswordFile)
+	}
+	return RsyncSource{Base: base, Args: args}
+}
+
+// checkTransport returns problems with the transport settings of j.
+func (j *JobConfig) checkTransport() (problems []string) {
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	t := j.Transport
+	if t == nil {
+		return nil
+	}
+
+	switch t.Type {
+	case "", TransportSSH, TransportPush:
+	case TransportLocal:
+		if !path.IsAbs(t.Path) {
+			add("transport.path %q must be an absolute path", t.Path)
+		}
+	case TransportRsyncd:
+		if strings.Trim(t.Module, "/") == "" {
+			add("transport.module is missing")
+		}
+		if strings.ContainsAny(t.User, "@:/ ") {
+			add("invalid transport.user %q", t.User)
+		}
+	case TransportZFSSend:
+		problems = append(problems, t.checkDatasets()...)
+	default:
+		add("unknown transport.type %q, expected ssh, local, rsyncd, zfs-send or push", t.Type)
+	}
+	return problems
+}
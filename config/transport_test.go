@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRsyncSourceString(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("/", RsyncSource{}.String())
+	assert.Equal("root@example.com:/", RsyncSource{Base: "root@example.com:"}.String())
+	assert.Equal("root@example.com:/mnt/snap/", RsyncSource{Base: "root@example.com:", Path: "mnt/snap/"}.String())
+	assert.Equal("/srv/nfs/example.com/", RsyncSource{Path: "/srv/nfs/example.com"}.String())
+}
+
+func TestDaemonSource(t *testing.T) {
+	assert := assert.New(t)
+
+	tc := TransportConfig{Type: TransportRsyncd, Module: "/backup/"}
+	assert.Equal(RsyncSource{Base: "rsync://example.com/backup"}, tc.DaemonSource("example.com"))
+
+	tc = TransportConfig{Type: TransportRsyncd, Module: "backup/www", User: "zackup", Port: 8873, PasswordFile: "/etc/zackup/rsyncd.secret"}
+	src := tc.DaemonSource("example.com")
+	assert.Equal("rsync://zackup@example.com:8873/backup/www/", src.String())
+	assert.Equal([]string{"--password-file=/etc/zackup/rsyncd.secret"}, src.Args)
+
+	args := (&RsyncConfig{}).BuildArgVector(src, "/zackup/example.com", ArgOptions{})
+	assert.NotContains(args, "-e")
+	assert.Equal([]string{"rsync://zackup@example.com:8873/backup/www/", "/zackup/example.com/"}, args[len(args)-2:])
+}
+
+func TestTreeTransport(t *testing.T) {
+	tr := loadTree(t, map[string]string{
+		"config.yml":            "parallel: 1\nroot_dataset: zpool/zackup\nmount_base: /zpool/zackup\n",
+		"globals.yml":           "transport:\n  type: rsyncd\n  module: backup\nlocal_pre_script: echo local\n",
+		"hosts/example.com.yml": "---\n",
+		"hosts/nfs.com.yml":     "transport:\n  type: local\n  path: /srv/nfs\nstreams:\n- name: a\n  command: echo a\n",
+		"hosts/laptop.com.yml":  "transport:\n  type: push\npre_script: echo pre\nstreams:\n- name: a\n  command: echo a\n",
+		"hosts/ssh.com.yml":     "transport:\n  type: ssh\nsource_snapshot:\n  type: lvm\n  source: vg0/root\n",
+		"hosts/invalid.com.yml": "transport:\n  type: ftp\n",
+		"hosts/rsyncd.com.yml": "transport:\n  type: rsyncd\n  module: /\n  user: a@b\n" +
+			"pre_script: echo pre\nstreams:\n- name: a\n  command: echo a\ndatabases:\n- type: postgres\n",
+		"hosts/relative.com.yml": "transport:\n  type: local\n  path: srv/nfs\nsource_snapshot:\n  type: lvm\n  source: vg0/root\n",
+	})
+
+	assert := assert.New(t)
+	assert.Equal(TransportRsyncd, tr.Host("example.com").TransportType())
+	assert.False(tr.Host("example.com").HasShell())
+	assert.Equal(TransportLocal, tr.Host("nfs.com").TransportType())
+	assert.True(tr.Host("nfs.com").HasShell())
+	assert.Equal(TransportSSH, tr.Host("ssh.com").TransportType())
+	assert.True(tr.Host("laptop.com").IsPush())
+	assert.False(tr.Host("ssh.com").IsPush())
+
+	assert.Equal([]string{
+		`hosts/invalid.com.yml (host invalid.com): unknown transport.type "ftp", expected ssh, local, rsyncd, zfs-send or push`,
+		`hosts/laptop.com.yml (host laptop.com): streams are not supported with transport push`,
+		`hosts/relative.com.yml (host relative.com): transport.path "srv/nfs" must be an absolute path`,
+		`hosts/relative.com.yml (host relative.com): source_snapshot is not supported with transport local`,
+		`hosts/rsyncd.com.yml (host rsyncd.com): transport.module is missing`,
+		`hosts/rsyncd.com.yml (host rsyncd.com): invalid transport.user "a@b"`,
+		`hosts/rsyncd.com.yml (host rsyncd.com): pre_script is not supported with transport rsyncd`,
+		`hosts/rsyncd.com.yml (host rsyncd.com): streams are not supported with transport rsyncd`,
+		`hosts/rsyncd.com.yml (host rsyncd.com): databases are not supported with transport rsyncd`,
+	}, findings(tr))
+}
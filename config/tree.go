@@ -1,13 +1,12 @@
 package config
 
 import (
-	"os"
+	"fmt"
 	"path"
 	"sort"
 	"sync"
 
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 )
 
 // Tree is the internal representation of the configuration directory
@@ -24,20 +23,43 @@ type Tree interface {
 	// GetHosts returns the list of configured hosts.
 	Hosts() []string
 
+	// Groups returns the list of configured host groups.
+	Groups() []string
+
+	// GroupHosts returns the list of hosts, which are member of the
+	// given group.
+	GroupHosts(group string) []string
+
 	// GetHost returns a copy of the job description for a single host.
 	// If the host is unknown, this returns nil.
 	Host(name string) *JobConfig
 
 	// Service returns a copy of the current service configuration.
 	Service() *ServiceConfig
+
+	// Reload re-reads the root config directory. If the new config is
+	// invalid, the previous config is kept and an error is returned.
+	Reload() error
+
+	// Findings returns the problems found while loading the config.
+	Findings() []Finding
+
+	// Describe renders the merged config for a host as YAML, annotated
+	// with the origin of each value.
+	Describe(host string) ([]byte, error)
 }
 
 type tree struct {
 	root string
 
-	service *ServiceConfig
-	global  *JobConfig
-	hosts   HostConfigs
+	service  *ServiceConfig
+	global   *JobConfig
+	groups   HostConfigs // group name → config
+	hosts    HostConfigs
+	findings []Finding
+
+	lenient bool      // see CheckTree
+	broken  []Finding // files, which could not be read in lenient mode
 
 	sync.RWMutex
 }
@@ -47,6 +69,16 @@ func NewTree(root string) Tree {
 	return &tree{root: root}
 }
 
+// CheckTree loads the config tree in root for reporting problems (see
+// "zackup config check"). Unlike SetRoot, config files which cannot be
+// read or decoded don't abort loading, they are skipped and reported as
+// findings instead.
+func CheckTree(root string) Tree {
+	t := &tree{root: root, lenient: true}
+	_ = t.load() // errors are recorded as findings
+	return t
+}
+
 func (t *tree) SetRoot(newRoot string) error {
 	t.RLock()
 	currentRoot := t.root
@@ -63,6 +95,8 @@ func (t *tree) SetRoot(newRoot string) error {
 }
 
 func (t *tree) Root() string {
+	t.RLock()
+	defer t.RUnlock()
 	return t.root
 }
 
@@ -78,6 +112,15 @@ func (t *tree) Service() *ServiceConfig {
 	return &dup
 }
 
+func (t *tree) Findings() []Finding {
+	t.RLock()
+	defer t.RUnlock()
+
+	res := make([]Finding, len(t.findings))
+	copy(res, t.findings)
+	return res
+}
+
 func (t *tree) Hosts() []string {
 	t.RLock()
 	res := make([]string, 0, len(t.hosts))
@@ -89,70 +132,185 @@ func (t *tree) Hosts() []string {
 	return res
 }
 
+func (t *tree) Groups() []string {
+	t.RLock()
+	res := make([]string, 0, len(t.groups))
+	for group := range t.groups {
+		res = append(res, group)
+	}
+	t.RUnlock()
+	sort.Strings(res)
+	return res
+}
+
+func (t *tree) GroupHosts(group string) []string {
+	t.RLock()
+	var res []string
+	for host, job := range t.hosts {
+		for _, g := range job.Groups {
+			if g == group {
+				res = append(res, host)
+				break
+			}
+		}
+	}
+	t.RUnlock()
+	sort.Strings(res)
+	return res
+}
+
 func (t *tree) Host(name string) *JobConfig {
 	t.RLock()
 	defer t.RUnlock()
 	if job, ok := t.hosts[name]; ok {
-		j := &JobConfig{host: job.host}
-		j.mergeGlobals(job)
-		return j
+		return job.clone()
 	}
 	return nil
 }
 
-// Reload (re-) reads the Tree.Root directory into memory.
+// Reload (re-) reads the Tree.Root directory into memory. If the new
+// config is invalid (or, in strict mode, contains problems), it is
+// rejected and the previous config is kept.
 func (t *tree) Reload() error {
 	t.Lock()
 	defer t.Unlock()
-	return t.reload()
+	return t.reloadStrict()
+}
+
+func (t *tree) reloadStrict() error {
+	next := &tree{root: t.root}
+	if err := next.load(); err != nil {
+		return err
+	}
+	if n := len(next.findings); n > 0 && next.service.Strict {
+		return errors.Errorf("strict mode: %d config problem(s) found", n)
+	}
+	t.swap(next)
+	return nil
 }
 
 func (t *tree) reload() error {
+	next := &tree{root: t.root}
+	if err := next.load(); err != nil {
+		return err
+	}
+	t.swap(next)
+	return nil
+}
+
+// swap replaces the config in t with the one from next.
+func (t *tree) swap(next *tree) {
+	t.root = next.root
+	t.service = next.service
+	t.global = next.global
+	t.groups = next.groups
+	t.hosts = next.hosts
+	t.findings = next.findings
+}
+
+// load reads the config from t.root. Since it modifies t in place, it
+// should only be called on a fresh tree instance (see reload()).
+func (t *tree) load() error {
 	if t.root == "" {
 		t.root = DefaultRoot
 	}
 
 	// read service config
 	t.service = &ServiceConfig{}
-	if err := t.decodeYaml("config.yml", t.service); err != nil {
-		return errors.Wrap(err, "failed to load config.yml")
+	svcProblems, err := t.decodeYaml("config.yml", t.service)
+	if err != nil {
+		if err = t.fail("config.yml", "", errors.Wrap(err, "failed to load config.yml")); err != nil {
+			return err
+		}
 	}
 
 	// read global config
-	t.global = &JobConfig{}
-	if err := t.decodeYaml("globals.yml", t.global); err != nil {
-		return errors.Wrap(err, "failed to load globals.yml")
+	t.global = &JobConfig{file: "globals.yml"}
+	if t.global.problems, err = t.decodeYaml("globals.yml", t.global); err != nil {
+		if err = t.fail("globals.yml", "", errors.Wrap(err, "failed to load globals.yml")); err != nil {
+			return err
+		}
+	}
+	t.global.nameInlineScripts()
+
+	// read group configs
+	t.groups = make(HostConfigs)
+	if err := t.groups.readGroups(t.root, t.fail); err != nil {
+		return err
 	}
 
 	// read host configs
 	t.hosts = make(HostConfigs)
-	if err := t.hosts.readGlob(path.Join(t.root, "hosts/*/config.yml"), pathToHostVariantA); err != nil {
+	if err := t.hosts.readGlob(t.root, "hosts/*/config.yml", pathToHostVariantA, t.fail); err != nil {
 		return err
 	}
-	if err := t.hosts.readGlob(path.Join(t.root, "hosts/*.yml"), pathToHostVariantB); err != nil {
+	if err := t.hosts.readGlob(t.root, "hosts/*.yml", pathToHostVariantB, t.fail); err != nil {
 		return err
 	}
-	if err := t.hosts.readHooks(t.root); err != nil {
+	if err := t.hosts.readHooks(t.root, t.fail); err != nil {
 		return err
 	}
 
-	// merge global config into host configs
+	// validate configs, before global values are merged in
+	t.findings = append(t.broken, t.service.validate("config.yml", svcProblems)...)
+	t.findings = append(t.findings, t.global.validate("globals.yml", t.service)...)
+	for _, group := range t.groups {
+		t.findings = append(t.findings, group.validate(group.file, t.service)...)
+	}
 	for _, job := range t.hosts {
-		job.mergeGlobals(t.global)
+		t.findings = append(t.findings, job.validate(job.file, t.service)...)
+		t.findings = append(t.findings, t.validateGroups(job)...)
 	}
 
+	// merge global and group configs into host configs
+	for _, job := range t.hosts {
+		job.inherit(t.layers(job)...)
+		t.findings = append(t.findings, job.validateMerged()...)
+	}
+	sortFindings(t.findings)
+
 	return nil
 }
 
-func (t *tree) decodeYaml(name string, v interface{}) error {
-	if !path.IsAbs(name) {
-		name = path.Join(t.root, name)
-	}
-	f, err := os.Open(name)
-	if err != nil {
+// fail returns err, unless t is lenient (see CheckTree). Then, err is
+// recorded as finding for the given file and host, and nil is returned.
+func (t *tree) fail(file, host string, err error) error {
+	if !t.lenient {
 		return err
 	}
-	defer f.Close()
+	t.broken = append(t.broken, Finding{File: file, Host: host, Message: err.Error()})
+	return nil
+}
+
+// layers returns the configs a job inherits from, ordered by increasing
+// precedence: globals first, then the job's groups. Unknown groups are
+// skipped (see validateGroups).
+func (t *tree) layers(job *JobConfig) []*JobConfig {
+	layers := []*JobConfig{t.global}
+	for _, name := range job.Groups {
+		if group, ok := t.groups[name]; ok {
+			layers = append(layers, group)
+		}
+	}
+	return layers
+}
+
+func (t *tree) validateGroups(job *JobConfig) (findings []Finding) {
+	for _, name := range job.Groups {
+		if _, ok := t.groups[name]; !ok {
+			findings = append(findings, Finding{
+				File:    job.file,
+				Host:    job.host,
+				Message: fmt.Sprintf("unknown group %q", name),
+			})
+		}
+	}
+	return findings
+}
 
-	return yaml.NewDecoder(f).Decode(v)
+func (t *tree) decodeYaml(name string, v interface{}) ([]string, error) {
+	if !path.IsAbs(name) {
+		name = path.Join(t.root, name)
+	}
+	return decodeFile(name, v)
 }
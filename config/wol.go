@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Defaults for WakeOnLANConfig.
+const (
+	DefaultWakeBroadcast = "255.255.255.255"
+	DefaultWakePort      = "9"
+	DefaultWakeWait      = 2 * time.Minute
+)
+
+// WakeOnLANConfig enables sending a Wake-on-LAN magic packet, if the host
+// is not reachable at the start of a backup.
+type WakeOnLANConfig struct {
+	// MAC is the hardware address of the host, e.g. "00:11:22:33:44:55".
+	MAC string `yaml:"mac"`
+
+	// Broadcast is the destination of the magic packet, as "ip[:port]".
+	// Defaults to DefaultWakeBroadcast, port DefaultWakePort.
+	Broadcast string `yaml:"broadcast"`
+
+	// Wait limits the time to wait for the host to come up. Defaults to
+	// DefaultWakeWait.
+	Wait *duration `yaml:"wait"`
+
+	// Shutdown is executed on the remote host after the backup, if the
+	// host was woken up by zackup, e.g. "shutdown -h +1".
+	Shutdown string `yaml:"shutdown"`
+}
+
+func (w *WakeOnLANConfig) clone() *WakeOnLANConfig {
+	dup := *w
+	if w.Wait != nil {
+		wait := *w.Wait
+		dup.Wait = &wait
+	}
+	return &dup
+}
+
+// HardwareAddr returns the parsed MAC address.
+func (w *WakeOnLANConfig) HardwareAddr() (net.HardwareAddr, error) {
+	mac, err := net.ParseMAC(w.MAC)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("expected a 48-bit MAC address, got %q", w.MAC)
+	}
+	return mac, nil
+}
+
+// Address returns the UDP destination of the magic packet.
+func (w *WakeOnLANConfig) Address() string {
+	addr := w.Broadcast
+	if addr == "" {
+		addr = DefaultWakeBroadcast
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, DefaultWakePort)
+	}
+	return addr
+}
+
+// MaxWait returns the time to wait for the host to come up.
+func (w *WakeOnLANConfig) MaxWait() time.Duration {
+	if w.Wait == nil || *w.Wait <= 0 {
+		return DefaultWakeWait
+	}
+	return time.Duration(*w.Wait)
+}
+
+// check returns problems with w.
+func (w *WakeOnLANConfig) check() (problems []string) {
+	if w.MAC == "" {
+		problems = append(problems, "wake_on_lan.mac is missing")
+	} else if _, err := w.HardwareAddr(); err != nil {
+		problems = append(problems, fmt.Sprintf("invalid wake_on_lan.mac: %v", err))
+	}
+	host, _, err := net.SplitHostPort(w.Address())
+	if err != nil || net.ParseIP(host) == nil {
+		problems = append(problems, fmt.Sprintf("invalid wake_on_lan.broadcast %q, expected ip[:port]", w.Broadcast))
+	}
+	return problems
+}
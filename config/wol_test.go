@@ -0,0 +1,34 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWakeOnLAN(t *testing.T) {
+	tr := loadTree(t, map[string]string{
+		"config.yml":  "parallel: 1\nroot_dataset: zpool/zackup\nmount_base: /zpool/zackup\n",
+		"globals.yml": "---\n",
+		"hosts/ws.com.yml": "wake_on_lan:\n  mac: 00:11:22:aa:bb:cc\n  broadcast: 192.168.1.255\n" +
+			"  wait: 5m\n  shutdown: shutdown -h +1\n",
+		"hosts/invalid.com.yml": "wake_on_lan:\n  mac: 00:11:22\n  broadcast: example.com:9\n",
+		"hosts/nas.com.yml":     "transport: {type: rsyncd, module: backup}\nwake_on_lan: {mac: 00:11:22:aa:bb:cc, shutdown: poweroff}\n",
+	})
+
+	assert := assert.New(t)
+	w := tr.Host("ws.com").WakeOnLAN
+	require.NotNil(t, w)
+	assert.Equal("192.168.1.255:9", w.Address())
+	assert.Equal(5*time.Minute, w.MaxWait())
+	assert.Equal("255.255.255.255:9", (&WakeOnLANConfig{}).Address())
+	assert.Equal(DefaultWakeWait, (&WakeOnLANConfig{}).MaxWait())
+
+	assert.Equal([]string{
+		`hosts/invalid.com.yml (host invalid.com): invalid wake_on_lan.mac: address 00:11:22: invalid MAC address`,
+		`hosts/invalid.com.yml (host invalid.com): invalid wake_on_lan.broadcast "example.com:9", expected ip[:port]`,
+		`hosts/nas.com.yml (host nas.com): wake_on_lan.shutdown is not supported with transport rsyncd`,
+	}, findings(tr))
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ZFSSendPrefix prefixes the names of the snapshots and bookmarks, which
+// the zfs-send transport creates on the remote host and receives locally.
+const ZFSSendPrefix = "zackup_"
+
+// ZFSSendName returns the snapshot name for a run started at t.
+func ZFSSendName(t time.Time) string {
+	return ZFSSendPrefix + t.UTC().Format("20060102T150405Z")
+}
+
+// ZFSListScript returns a script listing the snapshots and bookmarks of
+// the remote dataset ds, oldest first.
+func ZFSListScript(ds string) []string {
+	return []string{"zfs list -H -o name -t snapshot,bookmark -s createtxg -d 1 " + ShellQuote(ds)}
+}
+
+// ZFSSnapshotScript returns a script creating the snapshot ds@name.
+func ZFSSnapshotScript(ds, name string) []string {
+	return []string{"zfs snapshot " + ShellQuote(ds+"@"+name)}
+}
+
+// ZFSSendScript returns a script writing a replication stream of
+// ds@name to stdout. The stream is incremental, if base (a full
+// snapshot or bookmark name, i.e. "ds@x" or "ds#x") is not empty.
+func ZFSSendScript(ds, base, name string) []string {
+	cmd := "zfs send -p"
+	if base != "" {
+		cmd += " -i " + ShellQuote(base)
+	}
+	return []string{cmd + " " + ShellQuote(ds+"@"+name)}
+}
+
+// ZFSReleaseScript returns a script, which converts ds@name into a
+// bookmark (the base of the next incremental stream), and destroys the
+// given obsolete snapshots and bookmarks (full names).
+func ZFSReleaseScript(ds, name string, obsolete []string) []string {
+	script := []string{
+		"zfs bookmark " + ShellQuote(ds+"@"+name) + " " + ShellQuote(ds+"#"+name),
+		"zfs destroy " + ShellQuote(ds+"@"+name),
+	}
+	for _, o := range obsolete {
+		script = append(script, "zfs destroy "+ShellQuote(o))
+	}
+	return script
+}
+
+// checkDatasets returns problems with the datasets of a zfs-send
+// transport.
+func (t *TransportConfig) checkDatasets() (problems []string) {
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(t.Datasets) == 0 {
+		add("transport.datasets is missing")
+	}
+	seen := make(map[string]bool)
+	for i, ds := range t.Datasets {
+		if !isDatasetName(ds) {
+			add("transport.datasets[%d]: invalid dataset name %q", i, ds)
+		} else if seen[ds] {
+			add("transport.datasets[%d]: %q is not unique", i, ds)
+		}
+		seen[ds] = true
+	}
+	return problems
+}
+
+// isDatasetName checks whether name is a file system name (without
+// snapshot or bookmark part).
+func isDatasetName(name string) bool {
+	if name == "" || strings.ContainsAny(name, "@#%\x00\n\t ") {
+		return false
+	}
+	for _, c := range strings.Split(name, "/") {
+		if c == "" || c == "." || c == ".." {
+			return false
+		}
+	}
+	return true
+}
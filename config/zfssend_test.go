@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZFSSendScripts(t *testing.T) {
+	assert := assert.New(t)
+
+	name := ZFSSendName(time.Date(2026, 10, 18, 3, 4, 5, 0, time.FixedZone("CEST", 7200)))
+	assert.Equal("zackup_20261018T010405Z", name)
+
+	assert.Equal([]string{
+		"zfs list -H -o name -t snapshot,bookmark -s createtxg -d 1 'tank/data'",
+	}, ZFSListScript("tank/data"))
+	assert.Equal([]string{
+		"zfs snapshot 'tank/data@zackup_20261018T010405Z'",
+	}, ZFSSnapshotScript("tank/data", name))
+	assert.Equal([]string{
+		"zfs send -p 'tank/data@zackup_20261018T010405Z'",
+	}, ZFSSendScript("tank/data", "", name))
+	assert.Equal([]string{
+		"zfs send -p -i 'tank/data#zackup_1' 'tank/data@zackup_20261018T010405Z'",
+	}, ZFSSendScript("tank/data", "tank/data#zackup_1", name))
+	assert.Equal([]string{
+		"zfs bookmark 'tank/data@zackup_20261018T010405Z' 'tank/data#zackup_20261018T010405Z'",
+		"zfs destroy 'tank/data@zackup_20261018T010405Z'",
+		"zfs destroy 'tank/data#zackup_1'",
+	}, ZFSReleaseScript("tank/data", name, []string{"tank/data#zackup_1"}))
+}
+
+func TestTransportCheckDatasets(t *testing.T) {
+	tc := TransportConfig{Type: TransportZFSSend}
+	assert.Equal(t, []string{"transport.datasets is missing"}, tc.checkDatasets())
+
+	tc.Datasets = []string{"tank", "tank/data", "tank/data", "/tank", "tank@snap", "tank/../etc", ""}
+	assert.Equal(t, []string{
+		`transport.datasets[2]: "tank/data" is not unique`,
+		`transport.datasets[3]: invalid dataset name "/tank"`,
+		`transport.datasets[4]: invalid dataset name "tank@snap"`,
+		`transport.datasets[5]: invalid dataset name "tank/../etc"`,
+		`transport.datasets[6]: invalid dataset name ""`,
+	}, tc.checkDatasets())
+}
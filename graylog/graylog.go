@@ -1,6 +1,7 @@
 package graylog
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -31,6 +32,10 @@ type Middleware interface {
 	Flush()
 	SetLevel(string)
 	SetEndpoint(string)
+
+	// SetRedactor installs a function, which is applied to the message
+	// and all string fields of an entry before it is sent to Graylog.
+	SetRedactor(func(string) string)
 }
 
 // NewMiddleware returns a new Middleware.
@@ -45,6 +50,7 @@ type middleware struct {
 	endpoint string
 	level    logrus.Level
 	hook     *graylog.GraylogHook
+	redact   func(string) string
 
 	sync.RWMutex
 }
@@ -56,9 +62,37 @@ func (gl *middleware) Fire(ent *logrus.Entry) error {
 	if gl.hook == nil || ent.Level > gl.level {
 		return nil
 	}
+	if gl.redact != nil {
+		ent = redactEntry(ent, gl.redact)
+	}
 	return gl.hook.Fire(ent)
 }
 
+// redactEntry returns a copy of ent with redacted message and fields.
+func redactEntry(ent *logrus.Entry, redact func(string) string) *logrus.Entry {
+	dup := *ent
+	dup.Message = redact(ent.Message)
+	dup.Data = make(logrus.Fields, len(ent.Data))
+	for k, v := range ent.Data {
+		switch val := v.(type) {
+		case string:
+			v = redact(val)
+		case []string: // e.g. command lines
+			list := make([]string, len(val))
+			for i, s := range val {
+				list[i] = redact(s)
+			}
+			v = list
+		case error:
+			v = redact(val.Error())
+		case fmt.Stringer:
+			v = redact(val.String())
+		}
+		dup.Data[k] = v
+	}
+	return &dup
+}
+
 func (gl *middleware) Levels() []logrus.Level {
 	return []logrus.Level{
 		logrus.PanicLevel,
@@ -92,6 +126,13 @@ func (gl *middleware) SetLevel(s string) {
 	}
 }
 
+func (gl *middleware) SetRedactor(fn func(string) string) {
+	gl.Lock()
+	defer gl.Unlock()
+
+	gl.redact = fn
+}
+
 func (gl *middleware) SetEndpoint(s string) {
 	gl.Lock()
 	defer gl.Unlock()
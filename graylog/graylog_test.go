@@ -0,0 +1,45 @@
+package graylog
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRedactEntry(t *testing.T) {
+	t.Parallel()
+
+	redact := func(s string) string {
+		return strings.ReplaceAll(s, "hunter2", "***")
+	}
+	command := []string{"mysqldump", "--password=hunter2"}
+	ent := &logrus.Entry{
+		Message: "login with hunter2",
+		Data: logrus.Fields{
+			"command": command,
+			"error":   errors.New("bad password hunter2"),
+			"exit":    1,
+		},
+	}
+
+	actual := redactEntry(ent, redact)
+	if actual.Message != "login with ***" {
+		t.Errorf("unexpected message %q", actual.Message)
+	}
+	expected := logrus.Fields{
+		"command": []string{"mysqldump", "--password=***"},
+		"error":   "bad password ***",
+		"exit":    1,
+	}
+	if !reflect.DeepEqual(actual.Data, expected) {
+		t.Errorf("expected %v, got %v", expected, actual.Data)
+	}
+
+	// the original entry is left untouched
+	if command[1] != "--password=hunter2" || ent.Message != "login with hunter2" {
+		t.Errorf("original entry was modified: %v", ent)
+	}
+}
@@ -19,7 +19,7 @@ func main() {
 }
 
 func handleSIGUSRx() {
-	sig := make(chan os.Signal)
+	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGUSR1)
 
 	for range sig {